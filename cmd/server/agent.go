@@ -0,0 +1,46 @@
+package main
+
+import (
+	"aeromonitor/internal/db"
+	"aeromonitor/internal/monitor"
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runAgent runs `aeromonitor agent`: a probe agent that checks the monitors
+// assigned to its location and reports to the main server.
+func runAgent(args []string) {
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	server := fs.String("server", os.Getenv("AGENT_SERVER_URL"), "URL of the main server")
+	token := fs.String("token", os.Getenv("AGENT_TOKEN"), "agent token issued by the server")
+	dbPath := fs.String("db", os.Getenv("AGENT_DB_PATH"), "local state database")
+	fs.Parse(args)
+
+	if *server == "" || *token == "" {
+		log.Fatal("agent requires a server URL and token (AGENT_SERVER_URL / AGENT_TOKEN or -server / -token)")
+	}
+	if *dbPath == "" {
+		*dbPath = "aeromonitor-agent.db"
+	}
+
+	database, err := db.InitDB(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to initialize agent database: %v", err)
+	}
+	defer database.Close()
+
+	engine := monitor.NewEngine(database, nil)
+	configureExec(engine)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runner := monitor.NewAgentRunner(engine, *server, *token)
+	if err := runner.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Fatalf("Agent stopped: %v", err)
+	}
+}
@@ -3,12 +3,19 @@ package main
 import (
 	"aeromonitor/internal/auth"
 	"aeromonitor/internal/db"
+	"aeromonitor/internal/leader"
 	"aeromonitor/internal/monitor"
 	"aeromonitor/internal/settings"
 	"aeromonitor/internal/setup"
+	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"strings"
 
@@ -23,14 +30,32 @@ func main() {
 		log.Println("No .env file found")
 	}
 
-	// Initialize Database
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = "aeromonitor.db"
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "agent":
+			runAgent(os.Args[2:])
+			return
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		}
 	}
-	database, err := db.InitDB(dbPath)
+
+	if err := runServer(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runServer runs the server until SIGINT or SIGTERM, then shuts down so the
+// queued heartbeats are written and the leader lease is released.
+func runServer() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Initialize Database
+	database, err := db.InitDB(databaseDSN())
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 	defer database.Close()
 
@@ -44,9 +69,58 @@ func main() {
 
 	// Initialize Monitor Engine
 	engine := monitor.NewEngine(database, settingsService)
+	configureExec(engine)
+
+	// Raw heartbeats older than HEARTBEAT_RETENTION_DAYS are deleted once
+	// rolled up into hourly and daily aggregates
+	if days, err := strconv.Atoi(os.Getenv("HEARTBEAT_RETENTION_DAYS")); err == nil && days > 0 {
+		engine.SetRetention(time.Duration(days) * 24 * time.Hour)
+		log.Printf("Keeping raw heartbeats for %d days", days)
+	}
+
+	// Optional high availability: instances sharing the database elect a
+	// leader, which alone schedules checks and sends notifications
+	var elector *leader.Elector
+	if os.Getenv("HA_ENABLED") == "true" {
+		elector = newElector(database)
+		engine.SetLeadership(elector.IsLeader)
+		// Not derived from ctx: a signal must not release the lease while
+		// engine.Stop is still writing results
+		electorCtx, cancel := context.WithCancel(context.Background())
+		electorDone := make(chan struct{})
+		go func() {
+			elector.Run(electorCtx)
+			close(electorDone)
+		}()
+		// Runs after engine.Stop: release the lease once the last results are written
+		defer func() {
+			cancel()
+			<-electorDone
+		}()
+		log.Printf("High availability enabled, instance %s", elector.ID())
+	}
 	engine.Start()
 	defer engine.Stop()
 
+	// Optional UDP push ingress for constrained devices
+	if udpAddr := os.Getenv("PUSH_UDP_ADDR"); udpAddr != "" {
+		go func() {
+			if err := engine.ServeUDP(udpAddr); err != nil {
+				log.Printf("UDP push listener failed: %v", err)
+			}
+		}()
+	}
+
+	// Optional SMTP ingress for appliances that can only send email
+	if smtpAddr := os.Getenv("PUSH_SMTP_ADDR"); smtpAddr != "" {
+		receiver := engine.NewSMTPReceiver(os.Getenv("PUSH_SMTP_DOMAIN"))
+		go func() {
+			if err := receiver.ListenAndServe(smtpAddr); err != nil {
+				log.Printf("SMTP push listener failed: %v", err)
+			}
+		}()
+	}
+
 	// Initialize Echo
 	e := echo.New()
 
@@ -91,7 +165,14 @@ func main() {
 
 	// Public Health
 	api.GET("/health", func(c echo.Context) error {
-		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+		health := map[string]string{"status": "ok"}
+		if elector != nil {
+			health["role"] = "follower"
+			if elector.IsLeader() {
+				health["role"] = "leader"
+			}
+		}
+		return c.JSON(http.StatusOK, health)
 	})
 
 	// Public Status Routes (requires Bearer Auth if configured)
@@ -101,6 +182,9 @@ func main() {
 	// Public Push Routes (no auth needed)
 	engine.RegisterPushRoutes(api)
 
+	// Probe agent routes (agent token auth)
+	engine.RegisterAgentRoutes(api)
+
 	// Public Export Routes
 	engine.RegisterExportRoutes(api)
 
@@ -161,7 +245,55 @@ func main() {
 		port = "8080"
 	}
 	log.Printf("Server starting on :%s", port)
-	if err := e.Start(":" + port); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- e.Start(":" + port)
+	}()
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("server failed to start: %w", err)
+	case <-ctx.Done():
+	}
+	log.Println("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP shutdown: %v", err)
+	}
+	return nil
+}
+
+// configureExec enables exec monitors when allowed by the environment.
+func configureExec(engine *monitor.Engine) {
+	if os.Getenv("EXEC_MONITORS_ENABLED") == "true" {
+		allowlist := strings.Split(os.Getenv("EXEC_ALLOWLIST"), ",")
+		engine.AllowExec(allowlist)
+		log.Printf("Exec monitors enabled for: %s", os.Getenv("EXEC_ALLOWLIST"))
+	}
+}
+
+// databaseDSN selects PostgreSQL when DATABASE_URL is set, SQLite otherwise.
+func databaseDSN() string {
+	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+		return dsn
+	}
+	if path := os.Getenv("DB_PATH"); path != "" {
+		return path
+	}
+	return "aeromonitor.db"
+}
+
+// newElector configures leader election from HA_INSTANCE_ID and HA_LEASE_TTL.
+func newElector(database *db.DB) *leader.Elector {
+	id := os.Getenv("HA_INSTANCE_ID")
+	if id == "" {
+		hostname, _ := os.Hostname()
+		id = hostname + "-" + auth.GenerateRandomString(4)
+	}
+	ttl := 15 * time.Second
+	if s, err := strconv.Atoi(os.Getenv("HA_LEASE_TTL")); err == nil && s > 0 {
+		ttl = time.Duration(s) * time.Second
 	}
+	return leader.NewElector(leader.NewDBLock(database, "scheduler"), id, ttl)
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"aeromonitor/internal/db"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+// runMigrate runs `aeromonitor migrate status|up|down` against the database
+// configured by DATABASE_URL or DB_PATH.
+func runMigrate(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: aeromonitor migrate status|up|down")
+		os.Exit(2)
+	}
+
+	database, err := db.Open(databaseDSN())
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	switch args[0] {
+	case "status":
+		status, err := database.MigrationStatus()
+		if err != nil {
+			log.Fatalf("Failed to read migrations: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, m := range status {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = m.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+			if m.Version > db.LatestVersion() {
+				applied += " (unknown to this binary)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, applied)
+		}
+		w.Flush()
+	case "up":
+		applied, err := database.MigrateUp()
+		for _, m := range applied {
+			fmt.Printf("Applied %d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+	case "down":
+		m, err := database.MigrateDown()
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if m == nil {
+			fmt.Println("No migrations to revert")
+			return
+		}
+		fmt.Printf("Reverted %d %s\n", m.Version, m.Name)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q, want status, up or down\n", args[0])
+		os.Exit(2)
+	}
+}
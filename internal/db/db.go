@@ -1,93 +1,145 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-var Schema = `
-CREATE TABLE IF NOT EXISTS users (
-    id TEXT PRIMARY KEY,
-    username TEXT UNIQUE,
-    password_hash TEXT,
-    email TEXT,
-    role TEXT, -- admin, user
-    provider TEXT -- local, oidc
-);
-
-CREATE TABLE IF NOT EXISTS monitors (
-    id TEXT PRIMARY KEY,
-    owner_id TEXT,
-    name TEXT,
-    type TEXT, -- http, tcp, ping, push
-    target TEXT,
-    interval INTEGER, -- default 20s
-    notification_channels TEXT, -- JSON array
-    metadata TEXT, -- JSON for specific config
-    monitor_group TEXT,
-    paused BOOLEAN DEFAULT FALSE,
-    FOREIGN KEY(owner_id) REFERENCES users(id)
-);
-
-CREATE TABLE IF NOT EXISTS heartbeats (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    monitor_id TEXT,
-    status TEXT, -- up, down
-    latency INTEGER,
-    message TEXT,
-    data TEXT, -- JSON for custom push data
-    timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY(monitor_id) REFERENCES monitors(id)
-);
-
-CREATE TABLE IF NOT EXISTS notifications (
-    id TEXT PRIMARY KEY,
-    name TEXT,
-    type TEXT, -- slack, bark, email
-    config TEXT -- JSON encoded settings
-);
-
-CREATE TABLE IF NOT EXISTS monitor_notifications (
-    monitor_id TEXT,
-    notification_id TEXT,
-    PRIMARY KEY(monitor_id, notification_id),
-    FOREIGN KEY(monitor_id) REFERENCES monitors(id),
-    FOREIGN KEY(notification_id) REFERENCES notifications(id)
-);
-
-CREATE TABLE IF NOT EXISTS status_pages (
-    id TEXT PRIMARY KEY,
-    name TEXT,
-    slug TEXT UNIQUE,
-    monitors TEXT, -- JSON array of monitor IDs
-    is_public BOOLEAN DEFAULT TRUE,
-    password_hash TEXT
-);
-
-CREATE TABLE IF NOT EXISTS settings (
-    key TEXT PRIMARY KEY,
-    value TEXT
-);
-`
-
-func InitDB(path string) (*sqlx.DB, error) {
-	db, err := sqlx.Connect("sqlite3", path+"?_loc=UTC")
+// Dialects
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+)
+
+// DB is the storage handle shared by all services. Queries are written once
+// with ? placeholders and SQL that both SQLite and PostgreSQL accept; DB
+// rebinds the placeholders for the driver in use. The sqlx handle is not
+// embedded, so only methods that rebind are available.
+type DB struct {
+	conn    *sqlx.DB
+	Dialect string
+}
+
+func (db *DB) Get(dest interface{}, query string, args ...interface{}) error {
+	return db.conn.Get(dest, db.conn.Rebind(query), args...)
+}
+
+func (db *DB) Select(dest interface{}, query string, args ...interface{}) error {
+	return db.conn.Select(dest, db.conn.Rebind(query), args...)
+}
+
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.conn.Exec(db.conn.Rebind(query), args...)
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.conn.ExecContext(ctx, db.conn.Rebind(query), args...)
+}
+
+// NamedExec binds :name parameters for the driver in use.
+func (db *DB) NamedExec(query string, arg interface{}) (sql.Result, error) {
+	return db.conn.NamedExec(query, arg)
+}
+
+func (db *DB) QueryRowx(query string, args ...interface{}) *sqlx.Row {
+	return db.conn.QueryRowx(db.conn.Rebind(query), args...)
+}
+
+func (db *DB) Close() error {
+	return db.conn.Close()
+}
+
+func (db *DB) Beginx() (*Tx, error) {
+	tx, err := db.conn.Beginx()
 	if err != nil {
 		return nil, err
 	}
+	return &Tx{tx: tx, Dialect: db.Dialect}, nil
+}
+
+// Tx is a transaction that rebinds placeholders like DB.
+type Tx struct {
+	tx      *sqlx.Tx
+	Dialect string
+}
 
-	_, err = db.Exec(Schema)
+func (tx *Tx) Get(dest interface{}, query string, args ...interface{}) error {
+	return tx.tx.Get(dest, tx.tx.Rebind(query), args...)
+}
+
+func (tx *Tx) Select(dest interface{}, query string, args ...interface{}) error {
+	return tx.tx.Select(dest, tx.tx.Rebind(query), args...)
+}
+
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.tx.Exec(tx.tx.Rebind(query), args...)
+}
+
+func (tx *Tx) NamedExec(query string, arg interface{}) (sql.Result, error) {
+	return tx.tx.NamedExec(query, arg)
+}
+
+func (tx *Tx) Preparex(query string) (*sqlx.Stmt, error) {
+	return tx.tx.Preparex(tx.tx.Rebind(query))
+}
+
+func (tx *Tx) Commit() error {
+	return tx.tx.Commit()
+}
+
+func (tx *Tx) Rollback() error {
+	return tx.tx.Rollback()
+}
+
+// isPostgresDSN reports whether dsn is a PostgreSQL connection URL rather
+// than a SQLite path.
+func isPostgresDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// sqliteOptions lets the dashboard read while heartbeats are written (WAL),
+// waits for the write lock instead of failing with "database is locked", and
+// takes it at the start of transactions so they never have to upgrade.
+// synchronous=NORMAL is durable in WAL mode except for the last commits on
+// power loss.
+const sqliteOptions = "_loc=UTC&_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL&_txlock=immediate"
+
+// Open connects to the database without touching the schema. A postgres://
+// URL selects PostgreSQL; anything else is the path of a SQLite database.
+func Open(dsn string) (*DB, error) {
+	if isPostgresDSN(dsn) {
+		conn, err := sqlx.Connect("pgx", dsn)
+		if err != nil {
+			return nil, err
+		}
+		return &DB{conn: conn, Dialect: DialectPostgres}, nil
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	conn, err := sqlx.Connect("sqlite3", dsn+sep+sqliteOptions)
 	if err != nil {
 		return nil, err
 	}
+	return &DB{conn: conn, Dialect: DialectSQLite}, nil
+}
 
-	// Migrations
-	// Attempt to add monitor_group column if it doesn't exist
-	// SQLite doesn't support IF NOT EXISTS for ADD COLUMN, so we ignore the error
-	_, _ = db.Exec("ALTER TABLE monitors ADD COLUMN monitor_group TEXT")
-
-	// Ensure no NULLs in monitor_group to avoid scan errors
-	_, _ = db.Exec("UPDATE monitors SET monitor_group = '' WHERE monitor_group IS NULL")
-
+// InitDB opens the database and brings its schema up to date. It refuses
+// databases migrated by a newer version of AeroMonitor.
+func InitDB(dsn string) (*DB, error) {
+	db, err := Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.MigrateUp(); err != nil {
+		db.Close()
+		return nil, err
+	}
 	return db, nil
 }
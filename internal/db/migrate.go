@@ -0,0 +1,463 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Migration is one numbered schema change. Migrations run in version order,
+// each in its own transaction together with its schema_migrations row.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *Tx) error
+	Down    func(tx *Tx) error // nil when the migration cannot be reverted
+}
+
+// migrations must stay append-only: released versions are never edited, new
+// columns and tables get a new migration.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		Up:      initialSchema,
+		// Reverting would drop users, monitors and settings: irreversible
+		Down: nil,
+	},
+	{
+		Version: 2,
+		Name:    "heartbeat_rollups",
+		Up: execSQL(`
+CREATE TABLE IF NOT EXISTS heartbeat_rollups_hourly (
+    monitor_id TEXT,
+    bucket DATETIME, -- start of the hour, UTC
+    total_count INTEGER,
+    up_count INTEGER,
+    down_count INTEGER,
+    degraded_count INTEGER,
+    maintenance_count INTEGER,
+    latency_min INTEGER, -- latency of up and degraded heartbeats
+    latency_avg REAL,
+    latency_p95 INTEGER,
+    latency_max INTEGER,
+    PRIMARY KEY(monitor_id, bucket)
+);
+
+CREATE TABLE IF NOT EXISTS heartbeat_rollups_daily (
+    monitor_id TEXT,
+    bucket DATETIME, -- start of the day, UTC
+    total_count INTEGER,
+    up_count INTEGER,
+    down_count INTEGER,
+    degraded_count INTEGER,
+    maintenance_count INTEGER,
+    latency_min INTEGER,
+    latency_avg REAL,
+    latency_p95 INTEGER,
+    latency_max INTEGER,
+    PRIMARY KEY(monitor_id, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_heartbeat_rollups_hourly_bucket ON heartbeat_rollups_hourly(bucket);
+CREATE INDEX IF NOT EXISTS idx_heartbeat_rollups_daily_bucket ON heartbeat_rollups_daily(bucket);
+CREATE INDEX IF NOT EXISTS idx_heartbeats_timestamp ON heartbeats(timestamp);
+CREATE INDEX IF NOT EXISTS idx_location_heartbeats_timestamp ON location_heartbeats(timestamp);
+`),
+		Down: execSQL(`
+DROP INDEX IF EXISTS idx_location_heartbeats_timestamp;
+DROP INDEX IF EXISTS idx_heartbeats_timestamp;
+DROP TABLE IF EXISTS heartbeat_rollups_daily;
+DROP TABLE IF EXISTS heartbeat_rollups_hourly;
+`),
+	},
+	{
+		Version: 3,
+		Name:    "heartbeat_indexes",
+		Up: execSQL(`
+CREATE INDEX IF NOT EXISTS idx_heartbeats_monitor_timestamp ON heartbeats(monitor_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_location_heartbeats_monitor_location ON location_heartbeats(monitor_id, location, timestamp);
+`),
+		Down: execSQL(`
+DROP INDEX IF EXISTS idx_location_heartbeats_monitor_location;
+DROP INDEX IF EXISTS idx_heartbeats_monitor_timestamp;
+`),
+	},
+	{
+		Version: 4,
+		Name:    "push_last_ping",
+		Up: func(tx *Tx) error {
+			if err := addColumn(tx, "push_runs", "last_ping", "DATETIME"); err != nil {
+				return err
+			}
+			return execSQL(pushLastPingBackfill)(tx)
+		},
+		Down: execSQL(`
+DELETE FROM push_runs WHERE started_at IS NULL;
+ALTER TABLE push_runs DROP COLUMN last_ping;
+`),
+	},
+	{
+		Version: 5,
+		Name:    "notification_queue_retries",
+		Up: func(tx *Tx) error {
+			if err := addColumn(tx, "notification_queue", "attempts", "INTEGER DEFAULT 0"); err != nil {
+				return err
+			}
+			return addColumn(tx, "notification_queue", "next_attempt", "DATETIME")
+		},
+		Down: execSQL(`
+ALTER TABLE notification_queue DROP COLUMN next_attempt;
+ALTER TABLE notification_queue DROP COLUMN attempts;
+`),
+	},
+}
+
+// pushLastPingBackfill seeds the last ping of push monitors from their
+// heartbeats, which until now were told apart from the timeout's own rows by
+// their data.
+const pushLastPingBackfill = `
+INSERT INTO push_runs (monitor_id, last_ping)
+SELECT h.monitor_id, MAX(h.timestamp) FROM heartbeats h
+JOIN monitors m ON m.id = h.monitor_id
+WHERE m.type = 'push'
+    AND COALESCE(h.data, '') NOT LIKE '%"missed_run"%'
+    AND COALESCE(h.data, '') NOT LIKE '%"runaway"%'
+    AND COALESCE(h.message, '') <> 'Heartbeat timeout'
+GROUP BY h.monitor_id
+ON CONFLICT(monitor_id) DO UPDATE SET last_ping = excluded.last_ping;
+`
+
+func init() {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			panic(fmt.Sprintf("db: migration %q has version %d, want %d", m.Name, m.Version, i+1))
+		}
+	}
+}
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer
+// version of AeroMonitor than this binary.
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+// ErrIrreversible is returned by MigrateDown for migrations without a Down.
+var ErrIrreversible = errors.New("migration cannot be reverted")
+
+// LatestVersion is the schema version this binary migrates to.
+func LatestVersion() int {
+	return len(migrations)
+}
+
+// MigrationStatus is a known or applied migration.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time // nil while pending
+}
+
+const migrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT,
+    applied_at DATETIME
+);
+`
+
+func (db *DB) ensureMigrationsTable() error {
+	_, err := db.Exec(db.translate(migrationsTable))
+	return err
+}
+
+// SchemaVersion returns the highest applied migration, 0 for an empty database.
+func (db *DB) SchemaVersion() (int, error) {
+	if err := db.ensureMigrationsTable(); err != nil {
+		return 0, err
+	}
+	var version int
+	err := db.Get(&version, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations")
+	return version, err
+}
+
+// MigrateUp applies the pending migrations and returns them. Instances
+// starting together are safe: whichever inserts the schema_migrations row
+// first applies the migration, the others wait for it and skip it.
+func (db *DB) MigrateUp() ([]Migration, error) {
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if current > LatestVersion() {
+		return nil, fmt.Errorf("%w: database is at version %d, this binary supports up to %d; upgrade AeroMonitor or run an older release", ErrSchemaTooNew, current, LatestVersion())
+	}
+
+	var applied []Migration
+	for _, m := range migrations[current:] {
+		ran, err := db.apply(m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		if ran {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+func (db *DB) apply(m Migration) (bool, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Claim the version first so concurrent instances serialize on the row
+	res, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?) ON CONFLICT(version) DO NOTHING",
+		m.Version, m.Name, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if err := m.Up(tx); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// MigrateDown reverts the latest applied migration and returns it, or nil
+// when no migration is applied. The initial schema is never reverted.
+func (db *DB) MigrateDown() (*Migration, error) {
+	current, err := db.SchemaVersion()
+	if err != nil || current == 0 {
+		return nil, err
+	}
+	if current > LatestVersion() {
+		return nil, fmt.Errorf("%w: migration %d is unknown to this binary", ErrSchemaTooNew, current)
+	}
+	m := migrations[current-1]
+	if m.Down == nil {
+		return nil, fmt.Errorf("%w: %d (%s)", ErrIrreversible, m.Version, m.Name)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := m.Down(tx); err != nil {
+		return nil, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+		return nil, err
+	}
+	return &m, tx.Commit()
+}
+
+// MigrationStatus lists the known migrations with the time they were applied,
+// followed by applied versions this binary does not know.
+func (db *DB) MigrationStatus() ([]MigrationStatus, error) {
+	if err := db.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Version   int       `db:"version"`
+		Name      string    `db:"name"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	if err := db.Select(&rows, "SELECT version, COALESCE(name, '') as name, applied_at FROM schema_migrations ORDER BY version"); err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status = append(status, MigrationStatus{Version: m.Version, Name: m.Name})
+	}
+	for _, r := range rows {
+		appliedAt := r.AppliedAt
+		if r.Version >= 1 && r.Version <= len(migrations) {
+			status[r.Version-1].AppliedAt = &appliedAt
+			continue
+		}
+		status = append(status, MigrationStatus{Version: r.Version, Name: r.Name, AppliedAt: &appliedAt})
+	}
+	return status, nil
+}
+
+// execSQL returns a migration step running statements written for SQLite,
+// translated for the dialect of the transaction.
+func execSQL(statements string) func(tx *Tx) error {
+	return func(tx *Tx) error {
+		_, err := tx.Exec(translate(tx.Dialect, statements))
+		return err
+	}
+}
+
+// addColumn adds a column unless it exists, for databases created before
+// migrations were versioned.
+func addColumn(tx *Tx, table, column, definition string) error {
+	var n int
+	var err error
+	if tx.Dialect == DialectPostgres {
+		err = tx.Get(&n, "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?", table, column)
+	} else {
+		err = tx.Get(&n, "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column)
+	}
+	if err != nil || n > 0 {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, translate(tx.Dialect, definition)))
+	return err
+}
+
+// initialSchema creates the schema as of the first versioned release. The
+// statements are idempotent so unversioned databases are adopted as they are.
+func initialSchema(tx *Tx) error {
+	if err := execSQL(schemaV1)(tx); err != nil {
+		return err
+	}
+	if err := addColumn(tx, "monitors", "monitor_group", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumn(tx, "notifications", "schedule", "TEXT"); err != nil {
+		return err
+	}
+	// Ensure no NULLs in the added columns to avoid scan errors
+	return execSQL(`
+UPDATE monitors SET monitor_group = '' WHERE monitor_group IS NULL;
+UPDATE notifications SET schedule = '' WHERE schedule IS NULL;
+`)(tx)
+}
+
+// foreignKey matches the FOREIGN KEY clauses of the schema. SQLite does not
+// enforce them (foreign_keys is off), so they are left out on PostgreSQL to
+// keep the same behaviour, e.g. heartbeats outliving a deleted monitor. A
+// comment after the preceding column goes with the clause.
+var foreignKey = regexp.MustCompile(`,[ \t]*(--[^\n]*)?\s*FOREIGN KEY\([^)]*\) REFERENCES [^,\n]*`)
+
+// translate converts DDL written for SQLite to the given dialect.
+func translate(dialect, statements string) string {
+	if dialect != DialectPostgres {
+		return statements
+	}
+	statements = foreignKey.ReplaceAllString(statements, "")
+	return strings.NewReplacer(
+		"INTEGER PRIMARY KEY AUTOINCREMENT", "BIGSERIAL PRIMARY KEY",
+		"DATETIME", "TIMESTAMPTZ",
+		"REAL,", "DOUBLE PRECISION,",
+	).Replace(statements)
+}
+
+func (db *DB) translate(statements string) string {
+	return translate(db.Dialect, statements)
+}
+
+const schemaV1 = `
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,
+    username TEXT UNIQUE,
+    password_hash TEXT,
+    email TEXT,
+    role TEXT, -- admin, user
+    provider TEXT -- local, oidc
+);
+
+CREATE TABLE IF NOT EXISTS monitors (
+    id TEXT PRIMARY KEY,
+    owner_id TEXT,
+    name TEXT,
+    type TEXT, -- http, tcp, ping, push
+    target TEXT,
+    interval INTEGER, -- default 20s
+    notification_channels TEXT, -- JSON array
+    metadata TEXT, -- JSON for specific config
+    monitor_group TEXT,
+    paused BOOLEAN DEFAULT FALSE,
+    FOREIGN KEY(owner_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS heartbeats (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    monitor_id TEXT,
+    status TEXT, -- up, down
+    latency INTEGER,
+    message TEXT,
+    data TEXT, -- JSON for custom push data
+    timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(monitor_id) REFERENCES monitors(id)
+);
+
+CREATE TABLE IF NOT EXISTS push_runs (
+    monitor_id TEXT PRIMARY KEY,
+    started_at DATETIME, -- set while a job run is in progress
+    FOREIGN KEY(monitor_id) REFERENCES monitors(id)
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id TEXT PRIMARY KEY,
+    name TEXT,
+    type TEXT, -- slack, bark, email
+    config TEXT, -- JSON encoded settings
+    schedule TEXT -- JSON quiet hours / delivery window
+);
+
+CREATE TABLE IF NOT EXISTS notification_queue (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    notification_id TEXT,
+    title TEXT,
+    message TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(notification_id) REFERENCES notifications(id)
+);
+
+CREATE TABLE IF NOT EXISTS monitor_notifications (
+    monitor_id TEXT,
+    notification_id TEXT,
+    PRIMARY KEY(monitor_id, notification_id),
+    FOREIGN KEY(monitor_id) REFERENCES monitors(id),
+    FOREIGN KEY(notification_id) REFERENCES notifications(id)
+);
+
+CREATE TABLE IF NOT EXISTS status_pages (
+    id TEXT PRIMARY KEY,
+    name TEXT,
+    slug TEXT UNIQUE,
+    monitors TEXT, -- JSON array of monitor IDs
+    is_public BOOLEAN DEFAULT TRUE,
+    password_hash TEXT
+);
+
+CREATE TABLE IF NOT EXISTS agents (
+    id TEXT PRIMARY KEY,
+    name TEXT,
+    location TEXT,
+    token_hash TEXT UNIQUE, -- SHA-256 of the agent token
+    hostname TEXT DEFAULT '',
+    last_seen DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS location_heartbeats (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    monitor_id TEXT,
+    location TEXT, -- local or the agent location
+    status TEXT,
+    latency INTEGER,
+    message TEXT,
+    data TEXT,
+    timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(monitor_id) REFERENCES monitors(id)
+);
+
+CREATE TABLE IF NOT EXISTS leader_leases (
+    name TEXT PRIMARY KEY,
+    holder TEXT, -- instance ID of the leader
+    expires_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS settings (
+    key TEXT PRIMARY KEY,
+    value TEXT
+);
+`
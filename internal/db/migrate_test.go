@@ -0,0 +1,46 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		ddl  string
+		want string
+	}{
+		{
+			"CREATE TABLE t (\n    id INTEGER PRIMARY KEY AUTOINCREMENT,\n    at DATETIME DEFAULT CURRENT_TIMESTAMP,\n    latency REAL,\n    ok BOOLEAN\n);",
+			"CREATE TABLE t (\n    id BIGSERIAL PRIMARY KEY,\n    at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,\n    latency DOUBLE PRECISION,\n    ok BOOLEAN\n);",
+		},
+		{
+			"CREATE TABLE t (\n    monitor_id TEXT,\n    paused BOOLEAN DEFAULT FALSE,\n    FOREIGN KEY(monitor_id) REFERENCES monitors(id)\n);",
+			"CREATE TABLE t (\n    monitor_id TEXT,\n    paused BOOLEAN DEFAULT FALSE\n);",
+		},
+		{
+			"CREATE TABLE t (\n    a TEXT,\n    FOREIGN KEY(a) REFERENCES x(id),\n    FOREIGN KEY(b) REFERENCES y(id)\n);",
+			"CREATE TABLE t (\n    a TEXT\n);",
+		},
+		{
+			"CREATE TABLE t (\n    monitor_id TEXT PRIMARY KEY,\n    started_at DATETIME, -- set while running\n    FOREIGN KEY(monitor_id) REFERENCES monitors(id)\n);",
+			"CREATE TABLE t (\n    monitor_id TEXT PRIMARY KEY,\n    started_at TIMESTAMPTZ\n);",
+		},
+		{"CREATE INDEX IF NOT EXISTS idx ON t(monitor_id, timestamp);", "CREATE INDEX IF NOT EXISTS idx ON t(monitor_id, timestamp);"},
+	}
+	for _, tt := range tests {
+		if got := translate(DialectPostgres, tt.ddl); got != tt.want {
+			t.Errorf("translate(%q) = %q, want %q", tt.ddl, got, tt.want)
+		}
+		if got := translate(DialectSQLite, tt.ddl); got != tt.ddl {
+			t.Errorf("SQLite DDL changed: %q", got)
+		}
+	}
+
+	schema := translate(DialectPostgres, schemaV1)
+	for _, sqlite := range []string{"AUTOINCREMENT", "DATETIME", "FOREIGN KEY", "REAL,"} {
+		if strings.Contains(schema, sqlite) {
+			t.Errorf("PostgreSQL schema still contains %s", sqlite)
+		}
+	}
+}
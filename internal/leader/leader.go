@@ -0,0 +1,120 @@
+package leader
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"aeromonitor/internal/db"
+)
+
+// Lock is a lease shared by all instances. TryAcquire takes the lease when it
+// is free or expired, or renews it when holder already has it, and reports
+// whether holder is the leader until ttl passes.
+type Lock interface {
+	TryAcquire(ctx context.Context, holder string, ttl time.Duration) (bool, error)
+	Release(ctx context.Context, holder string) error
+}
+
+// DBLock keeps the lease in the leader_leases table of the shared database.
+type DBLock struct {
+	db   *db.DB
+	name string
+}
+
+func NewDBLock(database *db.DB, name string) *DBLock {
+	return &DBLock{db: database, name: name}
+}
+
+func (l *DBLock) TryAcquire(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	res, err := l.db.ExecContext(ctx, `
+		INSERT INTO leader_leases (name, holder, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE leader_leases.holder = excluded.holder OR leader_leases.expires_at < ?
+	`, l.name, holder, now.Add(ttl), now)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (l *DBLock) Release(ctx context.Context, holder string) error {
+	_, err := l.db.ExecContext(ctx, "DELETE FROM leader_leases WHERE name = ? AND holder = ?", l.name, holder)
+	return err
+}
+
+// Elector keeps trying to hold the lock and tracks whether this instance is
+// the leader. Leadership ends with the lease even if a renewal hangs.
+type Elector struct {
+	lock    Lock
+	id      string
+	ttl     time.Duration
+	until   atomic.Int64 // Lease expiry in Unix nanoseconds, 0 when following
+	leading bool         // Last renewal outcome, for logging transitions
+}
+
+func NewElector(lock Lock, id string, ttl time.Duration) *Elector {
+	return &Elector{lock: lock, id: id, ttl: ttl}
+}
+
+func (e *Elector) ID() string {
+	return e.id
+}
+
+func (e *Elector) IsLeader() bool {
+	return time.Now().UnixNano() < e.until.Load()
+}
+
+// Run renews the lease every third of its TTL until ctx is cancelled, then
+// releases it so another instance can take over right away.
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		e.renew(ctx)
+		select {
+		case <-ctx.Done():
+			e.until.Store(0)
+			if e.leading {
+				releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if err := e.lock.Release(releaseCtx, e.id); err != nil {
+					log.Printf("Failed to release leader lease: %v", err)
+				}
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) renew(ctx context.Context) {
+	start := time.Now()
+	acquired, err := e.lock.TryAcquire(ctx, e.id, e.ttl)
+	if err != nil {
+		// Step down rather than risk two leaders while the lock is unreachable
+		log.Printf("Leader lease renewal failed: %v", err)
+		acquired = false
+	}
+	if acquired {
+		e.until.Store(start.Add(e.ttl).UnixNano())
+	} else {
+		e.until.Store(0)
+	}
+
+	if e.leading != acquired {
+		e.leading = acquired
+		if acquired {
+			log.Printf("Instance %s became leader", e.id)
+		} else {
+			log.Printf("Instance %s is now a follower", e.id)
+		}
+	}
+}
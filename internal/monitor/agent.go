@@ -0,0 +1,209 @@
+package monitor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"aeromonitor/internal/auth"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Agent is a registered probe agent. Agents authenticate with a token that is
+// only shown when the agent is created; the server keeps its SHA-256.
+type Agent struct {
+	ID        string     `db:"id" json:"id"`
+	Name      string     `db:"name" json:"name"`
+	Location  string     `db:"location" json:"location"`
+	TokenHash string     `db:"token_hash" json:"-"`
+	Hostname  string     `db:"hostname" json:"hostname"`
+	LastSeen  *time.Time `db:"last_seen" json:"last_seen"`
+}
+
+// agentResult is a check result reported by an agent.
+type agentResult struct {
+	MonitorID string    `json:"monitor_id"`
+	Status    string    `json:"status"`
+	Latency   int       `json:"latency"`
+	Message   string    `json:"message"`
+	Data      string    `json:"data"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func hashAgentToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RegisterAgentRoutes registers the endpoints used by probe agents, which
+// authenticate with their agent token instead of a session.
+func (e *Engine) RegisterAgentRoutes(api *echo.Group) {
+	g := api.Group("/agent")
+	g.Use(e.agentAuth)
+	g.POST("/register", e.agentRegister)
+	g.GET("/monitors", e.agentMonitors)
+	g.POST("/results", e.agentResults)
+}
+
+func (e *Engine) agentAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		header := c.Request().Header.Get("Authorization")
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Missing agent token"})
+		}
+		var a Agent
+		if err := e.db.Get(&a, "SELECT * FROM agents WHERE token_hash = ?", hashAgentToken(token)); err != nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid agent token"})
+		}
+		e.db.Exec("UPDATE agents SET last_seen = ? WHERE id = ?", time.Now().UTC(), a.ID)
+		c.Set("agent", a)
+		return next(c)
+	}
+}
+
+func (e *Engine) agentRegister(c echo.Context) error {
+	a := c.Get("agent").(Agent)
+	var req struct {
+		Hostname string `json:"hostname"`
+	}
+	c.Bind(&req)
+	if _, err := e.db.Exec("UPDATE agents SET hostname = ? WHERE id = ?", req.Hostname, a.ID); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	log.Printf("Agent %s registered from %s (location %s)", a.Name, req.Hostname, a.Location)
+	return c.JSON(http.StatusOK, map[string]string{
+		"id":       a.ID,
+		"name":     a.Name,
+		"location": a.Location,
+	})
+}
+
+// agentMonitors lists the active monitors assigned to the agent's location.
+func (e *Engine) agentMonitors(c echo.Context) error {
+	a := c.Get("agent").(Agent)
+	var monitors []Monitor
+	if err := e.db.Select(&monitors, "SELECT * FROM monitors WHERE paused = FALSE"); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	assigned := []Monitor{}
+	for _, m := range monitors {
+		if cfg, _ := parseLocationConfig(m.Metadata); cfg.distributed() && cfg.includes(a.Location) {
+			assigned = append(assigned, m)
+		}
+	}
+	return c.JSON(http.StatusOK, assigned)
+}
+
+func (e *Engine) agentResults(c echo.Context) error {
+	a := c.Get("agent").(Agent)
+	var results []agentResult
+	if err := c.Bind(&results); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid results"})
+	}
+
+	now := time.Now().UTC()
+	accepted := 0
+	for _, r := range results {
+		switch r.Status {
+		case "up", "down", "degraded", "late":
+		default:
+			continue
+		}
+		var m Monitor
+		if err := e.db.Get(&m, "SELECT * FROM monitors WHERE id = ?", r.MonitorID); err != nil || m.Paused {
+			continue
+		}
+		if cfg, _ := parseLocationConfig(m.Metadata); !cfg.distributed() || !cfg.includes(a.Location) {
+			continue
+		}
+		ts := r.Timestamp.UTC()
+		if ts.IsZero() || ts.After(now) {
+			ts = now
+		}
+		e.saveLocationResult(m, a.Location, Result{
+			MonitorID: m.ID,
+			Status:    r.Status,
+			Latency:   r.Latency,
+			Message:   r.Message,
+			Data:      r.Data,
+		}, ts)
+		accepted++
+	}
+	return c.JSON(http.StatusOK, map[string]int{"accepted": accepted})
+}
+
+func (e *Engine) listAgents(c echo.Context) error {
+	agents := []Agent{}
+	if err := e.db.Select(&agents, "SELECT * FROM agents ORDER BY location, name"); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, agents)
+}
+
+func (e *Engine) createAgent(c echo.Context) error {
+	var req struct {
+		Name     string `json:"name"`
+		Location string `json:"location"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	req.Location = strings.TrimSpace(req.Location)
+	if req.Location == "" || req.Location == localLocation {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "A location other than \"local\" is required"})
+	}
+	if req.Name == "" {
+		req.Name = req.Location
+	}
+
+	token := auth.GenerateRandomString(32)
+	a := Agent{ID: uuid.New().String(), Name: req.Name, Location: req.Location, TokenHash: hashAgentToken(token)}
+	_, err := e.db.NamedExec(`INSERT INTO agents (id, name, location, token_hash, hostname)
+		VALUES (:id, :name, :location, :token_hash, :hostname)`, a)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	// The token is only returned here
+	return c.JSON(http.StatusCreated, map[string]interface{}{"agent": a, "token": token})
+}
+
+func (e *Engine) deleteAgent(c echo.Context) error {
+	if _, err := e.db.Exec("DELETE FROM agents WHERE id = ?", c.Param("id")); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// getMonitorLocations returns the latest result of each assigned location.
+func (e *Engine) getMonitorLocations(c echo.Context) error {
+	var m Monitor
+	if err := e.db.Get(&m, "SELECT * FROM monitors WHERE id = ?", c.Param("id")); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Monitor not found"})
+	}
+	cfg, _ := parseLocationConfig(m.Metadata)
+
+	type locationItem struct {
+		Location string `json:"location"`
+		locationResult
+	}
+	items := []locationItem{}
+	e.mu.RLock()
+	for _, loc := range cfg.Locations {
+		r := e.locationStatus[m.ID][loc]
+		if time.Since(r.Timestamp) > locationStaleAfter(m) {
+			r.Status = "unknown"
+		}
+		items = append(items, locationItem{Location: loc, locationResult: r})
+	}
+	e.mu.RUnlock()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"quorum":    cfg.quorum(),
+		"locations": items,
+	})
+}
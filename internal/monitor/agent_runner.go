@@ -0,0 +1,213 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	agentSyncInterval  = 30 * time.Second
+	agentFlushInterval = 5 * time.Second
+	agentRetryInterval = 10 * time.Second
+	agentMaxPending    = 5000 // Results kept while the server is unreachable
+)
+
+var errAgentUnauthorized = errors.New("server rejected the agent token")
+
+// AgentRunner is the probe agent side of `aeromonitor agent`. It pulls the
+// monitors assigned to its location, checks them with a local engine and
+// reports the results to the server.
+type AgentRunner struct {
+	engine *Engine
+	server string
+	token  string
+	client *http.Client
+
+	mu      sync.Mutex
+	pending []agentResult
+}
+
+// NewAgentRunner creates a runner; the engine should use a database of its own,
+// which holds the synced monitors and the state of stateful checks.
+func NewAgentRunner(engine *Engine, serverURL, token string) *AgentRunner {
+	return &AgentRunner{
+		engine: engine,
+		server: strings.TrimRight(serverURL, "/"),
+		token:  token,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Run registers with the server and checks the assigned monitors until ctx is
+// cancelled.
+func (a *AgentRunner) Run(ctx context.Context) error {
+	var info struct {
+		Name     string `json:"name"`
+		Location string `json:"location"`
+	}
+	for {
+		hostname, _ := os.Hostname()
+		err := a.call(ctx, http.MethodPost, "/api/agent/register", map[string]string{"hostname": hostname}, &info)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, errAgentUnauthorized) {
+			return err
+		}
+		log.Printf("Agent registration failed, retrying: %v", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(agentRetryInterval):
+		}
+	}
+	log.Printf("Agent %s registered for location %s", info.Name, info.Location)
+
+	a.engine.location = info.Location
+	a.engine.forward = a.enqueue
+	if err := a.syncMonitors(ctx); err != nil {
+		log.Printf("Agent monitor sync failed: %v", err)
+	}
+	a.engine.Start()
+	defer a.engine.Stop()
+
+	syncTicker := time.NewTicker(agentSyncInterval)
+	defer syncTicker.Stop()
+	flushTicker := time.NewTicker(agentFlushInterval)
+	defer flushTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Deliver what is left before exiting
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			a.flush(flushCtx)
+			cancel()
+			return nil
+		case <-syncTicker.C:
+			if err := a.syncMonitors(ctx); err != nil {
+				log.Printf("Agent monitor sync failed: %v", err)
+			}
+		case <-flushTicker.C:
+			a.flush(ctx)
+		}
+	}
+}
+
+// syncMonitors replaces the local monitors with the ones assigned by the
+// server. Heartbeats are pruned to the latest per monitor, which is all the
+// stateful checks (e.g. file_update) need.
+func (a *AgentRunner) syncMonitors(ctx context.Context) error {
+	var monitors []Monitor
+	if err := a.call(ctx, http.MethodGet, "/api/agent/monitors", nil, &monitors); err != nil {
+		return err
+	}
+
+	tx, err := a.engine.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM monitors"); err != nil {
+		return err
+	}
+	for _, m := range monitors {
+		_, err := tx.NamedExec(`INSERT INTO monitors (id, owner_id, name, type, target, interval, notification_channels, metadata, monitor_group, paused)
+			VALUES (:id, :owner_id, :name, :type, :target, :interval, :notification_channels, :metadata, :monitor_group, :paused)`, m)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM heartbeats WHERE monitor_id NOT IN (SELECT id FROM monitors)"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM heartbeats WHERE id NOT IN (SELECT MAX(id) FROM heartbeats GROUP BY monitor_id)"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (a *AgentRunner) enqueue(res Result) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = append(a.pending, agentResult{
+		MonitorID: res.MonitorID,
+		Status:    res.Status,
+		Latency:   res.Latency,
+		Message:   res.Message,
+		Data:      res.Data,
+		Timestamp: time.Now().UTC(),
+	})
+	if over := len(a.pending) - agentMaxPending; over > 0 {
+		a.pending = a.pending[over:]
+	}
+}
+
+// flush sends the pending results, keeping them for the next attempt when the
+// server cannot be reached.
+func (a *AgentRunner) flush(ctx context.Context) {
+	a.mu.Lock()
+	batch := a.pending
+	a.pending = nil
+	a.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	if err := a.call(ctx, http.MethodPost, "/api/agent/results", batch, nil); err != nil {
+		log.Printf("Agent failed to report %d results: %v", len(batch), err)
+		a.mu.Lock()
+		a.pending = append(batch, a.pending...)
+		if over := len(a.pending) - agentMaxPending; over > 0 {
+			a.pending = a.pending[over:]
+		}
+		a.mu.Unlock()
+	}
+}
+
+func (a *AgentRunner) call(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.server+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		return errAgentUnauthorized
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -22,6 +22,9 @@ func (e *Engine) RegisterPublicMonitorRoutes(api *echo.Group) {
 	api.GET("/monitors", e.listMonitors)
 	api.GET("/monitors/:id", e.getMonitor)
 	api.GET("/monitors/:id/heartbeats", e.getHeartbeats)
+	api.GET("/monitors/:id/locations", e.getMonitorLocations)
+	api.GET("/monitors/:id/uptime", e.getUptime)
+	api.GET("/monitors/:id/rollups", e.getRollups)
 }
 
 func (e *Engine) RegisterExportRoutes(api *echo.Group) {
@@ -46,16 +49,22 @@ func (e *Engine) RegisterAdminMonitorRoutes(api *echo.Group) {
 	api.PUT("/notifications/:id", e.updateOrCreateNotification)
 	api.DELETE("/notifications/:id", e.deleteNotificationChannel)
 	api.POST("/notifications/test", e.testNotification)
+
+	// Probe agents
+	api.GET("/agents", e.listAgents)
+	api.POST("/agents", e.createAgent)
+	api.DELETE("/agents/:id", e.deleteAgent)
 }
 
 func (e *Engine) listNotifications(c echo.Context) error {
 	var list []struct {
-		ID     string `db:"id" json:"id"`
-		Name   string `db:"name" json:"name"`
-		Type   string `db:"type" json:"type"`
-		Config string `db:"config" json:"config"`
+		ID       string `db:"id" json:"id"`
+		Name     string `db:"name" json:"name"`
+		Type     string `db:"type" json:"type"`
+		Config   string `db:"config" json:"config"`
+		Schedule string `db:"schedule" json:"schedule"`
 	}
-	err := e.db.Select(&list, "SELECT id, name, type, config FROM notifications")
+	err := e.db.Select(&list, "SELECT id, name, type, config, COALESCE(schedule, '') as schedule FROM notifications")
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -64,15 +73,19 @@ func (e *Engine) listNotifications(c echo.Context) error {
 
 func (e *Engine) createNotification(c echo.Context) error {
 	var n struct {
-		Name   string `json:"name"`
-		Type   string `json:"type"`
-		Config string `json:"config"`
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		Config   string `json:"config"`
+		Schedule string `json:"schedule"`
 	}
 	if err := c.Bind(&n); err != nil {
 		return err
 	}
+	if _, err := notification.ParseSchedule(n.Schedule); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
 	id := uuid.New().String()
-	_, err := e.db.Exec("INSERT INTO notifications (id, name, type, config) VALUES (?, ?, ?, ?)", id, n.Name, n.Type, n.Config)
+	_, err := e.db.Exec("INSERT INTO notifications (id, name, type, config, schedule) VALUES (?, ?, ?, ?, ?)", id, n.Name, n.Type, n.Config, n.Schedule)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -99,22 +112,27 @@ func (e *Engine) testNotification(c echo.Context) error {
 func (e *Engine) updateOrCreateNotification(c echo.Context) error {
 	id := c.Param("id")
 	var n struct {
-		Name   string `json:"name"`
-		Type   string `json:"type"`
-		Config string `json:"config"`
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		Config   string `json:"config"`
+		Schedule string `json:"schedule"`
 	}
 	if err := c.Bind(&n); err != nil {
 		return err
 	}
+	if _, err := notification.ParseSchedule(n.Schedule); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
 
 	_, err := e.db.Exec(`
-		INSERT INTO notifications (id, name, type, config) 
-		VALUES (?, ?, ?, ?)
+		INSERT INTO notifications (id, name, type, config, schedule) 
+		VALUES (?, ?, ?, ?, ?)
 		ON CONFLICT(id) DO UPDATE SET 
 			name = excluded.name,
 			type = excluded.type,
-			config = excluded.config
-	`, id, n.Name, n.Type, n.Config)
+			config = excluded.config,
+			schedule = excluded.schedule
+	`, id, n.Name, n.Type, n.Config, n.Schedule)
 
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
@@ -132,6 +150,7 @@ func (e *Engine) deleteNotificationChannel(c echo.Context) error {
 		log.Printf("Failed to delete notification associations: %v", err)
 		// Continue to delete the notification itself even if associations failed
 	}
+	e.db.Exec("DELETE FROM notification_queue WHERE notification_id = ?", id)
 
 	_, err = e.db.Exec("DELETE FROM notifications WHERE id = ?", id)
 	if err != nil {
@@ -142,9 +161,10 @@ func (e *Engine) deleteNotificationChannel(c echo.Context) error {
 
 type MonitorListItem struct {
 	Monitor
-	Status  string  `json:"status"`
-	Latency int     `json:"latency"`
-	Uptime  float64 `json:"uptime"`
+	Status    string            `json:"status"`
+	Latency   int               `json:"latency"`
+	Uptime    float64           `json:"uptime"`
+	Locations map[string]string `json:"locations,omitempty"` // Status per probe location
 }
 
 func (e *Engine) listMonitors(c echo.Context) error {
@@ -155,26 +175,7 @@ func (e *Engine) listMonitors(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	// Query 2: Fetch latest status and latency for all monitors in a single query
-	var latestHeartbeats []struct {
-		MonitorID string `db:"monitor_id"`
-		Status    string `db:"status"`
-		Latency   int    `db:"latency"`
-	}
-	err = e.db.Select(&latestHeartbeats, `
-		SELECT h1.monitor_id, h1.status, h1.latency
-		FROM heartbeats h1
-		INNER JOIN (
-			SELECT monitor_id, MAX(timestamp) as max_timestamp
-			FROM heartbeats
-			GROUP BY monitor_id
-		) h2 ON h1.monitor_id = h2.monitor_id AND h1.timestamp = h2.max_timestamp
-	`)
-	if err != nil {
-		log.Printf("[API WARN] Failed to fetch latest heartbeats: %v", err)
-	}
-
-	// Query 3: Calculate 24h uptime for all monitors in a single query
+	// Query 2: Calculate 24h uptime for all monitors in a single query
 	var uptimeStats []struct {
 		MonitorID  string `db:"monitor_id"`
 		TotalCount int    `db:"total_count"`
@@ -183,28 +184,17 @@ func (e *Engine) listMonitors(c echo.Context) error {
 	err = e.db.Select(&uptimeStats, `
 		SELECT 
 			monitor_id,
-			COUNT(*) as total_count,
-			SUM(CASE WHEN status = 'up' THEN 1 ELSE 0 END) as up_count
+			`+uptimeTotalSQL+` as total_count,
+			`+uptimeUpSQL+` as up_count
 		FROM heartbeats
-		WHERE timestamp > DATETIME('now', '-24 hours')
+		WHERE timestamp > ?
 		GROUP BY monitor_id
-	`)
+	`, time.Now().UTC().Add(-24*time.Hour))
 	if err != nil {
 		log.Printf("[API WARN] Failed to fetch uptime stats: %v", err)
 	}
 
 	// Build hash maps for O(1) lookup
-	statusMap := make(map[string]struct {
-		Status  string
-		Latency int
-	})
-	for _, h := range latestHeartbeats {
-		statusMap[h.MonitorID] = struct {
-			Status  string
-			Latency int
-		}{h.Status, h.Latency}
-	}
-
 	uptimeMap := make(map[string]struct {
 		TotalCount int
 		UpCount    int
@@ -223,22 +213,23 @@ func (e *Engine) listMonitors(c echo.Context) error {
 		latency := 0
 		uptime := 100.0
 
-		// Get status and latency from map
-		if s, ok := statusMap[m.ID]; ok {
-			status = s.Status
-			latency = s.Latency
+		// Status and latency of the latest heartbeat, kept in memory
+		if h, ok := e.latestHeartbeat(m.ID); ok {
+			status = h.Status
+			latency = h.Latency
 		}
 
 		// Calculate uptime from map
-		if u, ok := uptimeMap[m.ID]; ok && u.TotalCount > 0 {
-			uptime = (float64(u.UpCount) / float64(u.TotalCount)) * 100
+		if u, ok := uptimeMap[m.ID]; ok {
+			uptime = uptimePercent(u.UpCount, u.TotalCount)
 		}
 
 		list = append(list, MonitorListItem{
-			Monitor: m,
-			Status:  status,
-			Latency: latency,
-			Uptime:  uptime,
+			Monitor:   m,
+			Status:    status,
+			Latency:   latency,
+			Uptime:    uptime,
+			Locations: e.locationSummary(m),
 		})
 	}
 
@@ -255,6 +246,9 @@ func (e *Engine) createMonitor(c echo.Context) error {
 	}
 	m := req.Monitor
 	m.ID = uuid.New().String()
+	if err := e.validateMonitor(m); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
 
 	tx, err := e.db.Beginx()
 	if err != nil {
@@ -282,6 +276,98 @@ func (e *Engine) createMonitor(c echo.Context) error {
 	return c.JSON(http.StatusCreated, m)
 }
 
+// validateMonitor rejects monitor configurations that can never be checked.
+func (e *Engine) validateMonitor(m Monitor) error {
+	if _, err := parseRules(m.Metadata); err != nil {
+		return err
+	}
+	if m.Type == TypePush {
+		cfg := parsePushConfig(m.Metadata)
+		if _, _, err := cfg.schedule(); err != nil {
+			return err
+		}
+		if _, _, err := cfg.emailRegexes(); err != nil {
+			return err
+		}
+	}
+	if m.Type == TypeTCP || m.Type == TypeUDP {
+		if _, err := parseProbeConfig(m.Metadata); err != nil {
+			return err
+		}
+	}
+	if m.Type == TypeMQTT {
+		if _, err := parseMQTTConfig(m); err != nil {
+			return err
+		}
+	}
+	if m.Type == TypePostgres || m.Type == TypeMySQL || m.Type == TypeRedis {
+		if _, err := parseDatabaseConfig(m); err != nil {
+			return err
+		}
+	}
+	if m.Type == TypeGRPC {
+		if _, err := parseGRPCConfig(m.Metadata); err != nil {
+			return err
+		}
+	}
+	if m.Type == TypeNTRIP {
+		if _, err := parseNTRIPConfig(m.Metadata); err != nil {
+			return err
+		}
+	}
+	if m.Type == TypeSSH {
+		if _, err := parseSSHConfig(m.Metadata); err != nil {
+			return err
+		}
+	}
+	if m.Type == TypeWebSocket {
+		if _, err := parseWebSocketConfig(m.Metadata); err != nil {
+			return err
+		}
+	}
+	if m.Type == TypePrometheus {
+		if _, err := parsePrometheusConfig(m.Metadata); err != nil {
+			return err
+		}
+	}
+	if m.Type == TypeGroup {
+		cfg, err := parseGroupConfig(m)
+		if err != nil {
+			return err
+		}
+		if err := e.checkGroupCycle(m, cfg); err != nil {
+			return err
+		}
+	}
+	if m.Type == TypeFileUpdate {
+		if _, err := parseFileUpdateConfig(m); err != nil {
+			return err
+		}
+	}
+	if m.Type == TypeHTTPFlow {
+		if _, err := parseHTTPFlowConfig(m.Metadata); err != nil {
+			return err
+		}
+	}
+	if m.Type == TypeExec {
+		if _, _, err := e.parseExecConfig(m.Metadata); err != nil {
+			return err
+		}
+	}
+	if cfg, err := parseLocationConfig(m.Metadata); err != nil {
+		return err
+	} else if cfg.distributed() && (m.Type == TypePush || m.Type == TypeGroup) {
+		return fmt.Errorf("%s monitors cannot be assigned to locations", m.Type)
+	}
+	switch m.Type {
+	case TypeHTTP, TypeHTTPFlow, TypePrometheus, TypeWebSocket, TypeFileUpdate, TypeTCP:
+		if _, err := parseNetworkConfig(m.Metadata); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (e *Engine) getMonitor(c echo.Context) error {
 	id := c.Param("id")
 	var m Monitor
@@ -310,6 +396,9 @@ func (e *Engine) updateMonitor(c echo.Context) error {
 	}
 	m := req.Monitor
 	m.ID = id
+	if err := e.validateMonitor(m); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
 
 	tx, err := e.db.Beginx()
 	if err != nil {
@@ -339,6 +428,7 @@ func (e *Engine) updateMonitor(c echo.Context) error {
 	if err := tx.Commit(); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
+	e.resetRuleStreaks(id)
 
 	return c.JSON(http.StatusOK, m)
 }
@@ -349,33 +439,66 @@ func (e *Engine) deleteMonitor(c echo.Context) error {
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
+	e.mu.Lock()
+	delete(e.latest, id)
+	delete(e.ruleStreaks, id)
+	e.mu.Unlock()
 	return c.NoContent(http.StatusNoContent)
 }
 
+// getHeartbeats returns the last 7 days of a monitor, newest first: raw
+// heartbeats for the last day and one summary per hour before that, so the
+// history has no hole when raw heartbeats are kept for less than 7 days.
 func (e *Engine) getHeartbeats(c echo.Context) error {
 	id := c.Param("id")
+	now := time.Now().UTC()
+	since := now.Add(-7 * 24 * time.Hour)
+
+	// Raw heartbeats cover what is not rolled up yet in any case
+	split := now.Add(-heartbeatRawWindow).Truncate(time.Hour)
+	hourlyEnd, err := e.rollupEnd(hourlyRollups)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if hourlyEnd.Before(split) {
+		split = hourlyEnd
+	}
+	if split.Before(since) {
+		split = since
+	}
+
 	var heartbeats []Heartbeat
-	// Increase limit to accommodate 7 days of data (approx 30k points at 20s interval)
-	err := e.db.Select(&heartbeats, "SELECT id, monitor_id, status, latency, COALESCE(message, '') as message, COALESCE(data, '{}') as data, timestamp FROM heartbeats WHERE monitor_id = ? AND timestamp > DATETIME('now', '-7 days') ORDER BY timestamp DESC", id)
+	err = e.db.Select(&heartbeats, "SELECT id, monitor_id, status, latency, COALESCE(message, '') as message, COALESCE(data, '{}') as data, timestamp FROM heartbeats WHERE monitor_id = ? AND timestamp >= ? ORDER BY timestamp DESC", id, split)
 	if err != nil {
 		log.Printf("[API ERROR] Failed to fetch heartbeats for %s: %v", id, err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
-	return c.JSON(http.StatusOK, heartbeats)
+	older, err := e.rollupPoints(id, since, split)
+	if err != nil {
+		log.Printf("[API ERROR] Failed to fetch rollups for %s: %v", id, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, append(heartbeats, older...))
 }
 
 func (e *Engine) clearMonitorHistory(c echo.Context) error {
 	id := c.Param("id")
-	_, err := e.db.Exec("DELETE FROM heartbeats WHERE monitor_id = ?", id)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	// Write queued heartbeats first so none of them survive the clear
+	e.flushHeartbeats()
+	for _, table := range []string{"heartbeats", hourlyRollups.table, dailyRollups.table} {
+		if _, err := e.db.Exec("DELETE FROM "+table+" WHERE monitor_id = ?", id); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
 	}
+	e.mu.Lock()
+	delete(e.latest, id)
+	e.mu.Unlock()
 	return c.NoContent(http.StatusNoContent)
 }
 
 func (e *Engine) pauseMonitor(c echo.Context) error {
 	id := c.Param("id")
-	_, err := e.db.Exec("UPDATE monitors SET paused = 1 WHERE id = ?", id)
+	_, err := e.db.Exec("UPDATE monitors SET paused = TRUE WHERE id = ?", id)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -384,7 +507,7 @@ func (e *Engine) pauseMonitor(c echo.Context) error {
 
 func (e *Engine) resumeMonitor(c echo.Context) error {
 	id := c.Param("id")
-	_, err := e.db.Exec("UPDATE monitors SET paused = 0 WHERE id = ?", id)
+	_, err := e.db.Exec("UPDATE monitors SET paused = FALSE WHERE id = ?", id)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -453,19 +576,11 @@ func (e *Engine) exportMonitorData(c echo.Context) error {
 	start := time.Now().UTC().AddDate(0, 0, -30)
 	end := time.Now().UTC()
 
-	if startStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04", startStr); err == nil {
-			start = t
-		} else if t, err := time.Parse(time.RFC3339, startStr); err == nil {
-			start = t
-		}
+	if t, ok := parseTimeParam(startStr); ok {
+		start = t
 	}
-	if endStr != "" {
-		if t, err := time.Parse("2006-01-02T15:04", endStr); err == nil {
-			end = t
-		} else if t, err := time.Parse(time.RFC3339, endStr); err == nil {
-			end = t
-		}
+	if t, ok := parseTimeParam(endStr); ok {
+		end = t
 	}
 
 	var heartbeats []Heartbeat
@@ -578,7 +693,7 @@ func (e *Engine) getMonitorStatus(c echo.Context) error {
 		"timestamp": h.Timestamp.Format(time.RFC3339),
 	}
 
-	if h.Status == "up" {
+	if h.Status == "up" || h.Status == "degraded" {
 		if h.Type == string(TypePush) {
 			var jsonData interface{}
 			if err := json.Unmarshal([]byte(h.Data), &jsonData); err == nil {
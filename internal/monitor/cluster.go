@@ -0,0 +1,125 @@
+package monitor
+
+import (
+	"log"
+	"time"
+)
+
+// syncWindow is how far behind the newest synced row the sync keeps reading.
+// Batches of different instances commit out of id order, so a row with a
+// lower id can appear after higher ones were synced; rows are read by
+// timestamp instead and deduplicated by id.
+const syncWindow = 30 * time.Second
+
+// syncTail follows a table written by several instances.
+type syncTail struct {
+	cursor time.Time // Newest timestamp synced
+	seen   map[int64]time.Time
+}
+
+func newSyncTail() *syncTail {
+	return &syncTail{cursor: time.Now().UTC(), seen: make(map[int64]time.Time)}
+}
+
+// since is the start of the window to read.
+func (t *syncTail) since() time.Time {
+	return t.cursor.Add(-syncWindow)
+}
+
+// add records a row and reports whether it is new.
+func (t *syncTail) add(id int64, ts time.Time) bool {
+	if _, ok := t.seen[id]; ok {
+		return false
+	}
+	t.seen[id] = ts
+	// An instance with a clock ahead must not move the window past others
+	if ts.After(t.cursor) && !ts.After(time.Now().UTC()) {
+		t.cursor = ts
+	}
+	return true
+}
+
+// prune forgets the rows that left the window.
+func (t *syncTail) prune() {
+	since := t.since()
+	for id, ts := range t.seen {
+		if ts.Before(since) {
+			delete(t.seen, id)
+		}
+	}
+}
+
+// syncStatus applies heartbeats written since the last tick, including those
+// of other instances (push and agent results received by followers), so the
+// leader notifies about them and a follower is current when it takes over.
+func (e *Engine) syncStatus() {
+	var rows []Heartbeat
+	err := e.db.Select(&rows, "SELECT id, monitor_id, status, latency, COALESCE(message, '') as message, COALESCE(data, '') as data, timestamp FROM heartbeats WHERE timestamp >= ? ORDER BY timestamp, id", e.heartbeatTail.since())
+	if err != nil {
+		log.Printf("Error syncing heartbeats: %v", err)
+		return
+	}
+	for _, r := range rows {
+		if !e.heartbeatTail.add(r.ID, r.Timestamp) {
+			continue
+		}
+		// Rows this instance wrote, or older than a result it already
+		// applied, must not roll the status back and notify again
+		if e.setLatest(r) {
+			e.applyStatus(r.MonitorID, r.Status)
+		}
+	}
+	e.heartbeatTail.prune()
+	e.syncLocations()
+}
+
+// syncLocations loads the location votes received by other instances, so the
+// leader aggregates the votes of all agents whichever instance they report to.
+func (e *Engine) syncLocations() {
+	var rows []struct {
+		ID        int64     `db:"id"`
+		MonitorID string    `db:"monitor_id"`
+		Location  string    `db:"location"`
+		Status    string    `db:"status"`
+		Latency   int       `db:"latency"`
+		Message   string    `db:"message"`
+		Timestamp time.Time `db:"timestamp"`
+	}
+	err := e.db.Select(&rows, "SELECT id, monitor_id, location, status, latency, COALESCE(message, '') as message, timestamp FROM location_heartbeats WHERE timestamp >= ? ORDER BY timestamp, id", e.locationTail.since())
+	if err != nil {
+		log.Printf("Error syncing location results: %v", err)
+		return
+	}
+	changed := make(map[string]bool)
+	for _, r := range rows {
+		if !e.locationTail.add(r.ID, r.Timestamp) {
+			continue
+		}
+		if e.setLocationResult(r.MonitorID, r.Location, locationResult{Status: r.Status, Latency: r.Latency, Message: r.Message, Timestamp: r.Timestamp}) {
+			changed[r.MonitorID] = true
+		}
+	}
+	e.locationTail.prune()
+
+	if !e.leading() {
+		return
+	}
+	for id := range changed {
+		var m Monitor
+		if err := e.db.Get(&m, "SELECT * FROM monitors WHERE id = ?", id); err != nil || m.Paused {
+			continue
+		}
+		e.aggregateLocationVotes(m)
+	}
+}
+
+// stepDown releases what only the leader holds after losing the lease.
+func (e *Engine) stepDown() {
+	log.Println("Lost leadership, stopping checks")
+	e.pruneMQTTSubscriptions(map[string]bool{})
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// Check everything right away when leading again
+	e.lastChecks = make(map[string]time.Time)
+}
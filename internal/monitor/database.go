@@ -0,0 +1,291 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/redis/go-redis/v9"
+)
+
+const databaseCheckTimeout = 10 * time.Second
+
+// databaseConfig is the metadata of postgres, mysql and redis monitors. The
+// target is host[:port].
+type databaseConfig struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+	TLS      string `json:"tls"` // "", "true", "skip-verify"; postgres also accepts any sslmode
+
+	// Query (SQL) or command (Redis) to run, e.g. "SELECT 1" or "INFO replication"
+	Query string `json:"query"`
+
+	queryAssertion
+}
+
+// queryAssertion checks the result of a database query. Value is the first
+// column of the first row (SQL) or the reply (Redis); fields are the columns of
+// the first row (SQL) or the key:value lines of an INFO reply (Redis).
+type queryAssertion struct {
+	ExpectedValue  *string           `json:"expected_value"`
+	MinValue       *float64          `json:"min_value"`
+	MaxValue       *float64          `json:"max_value"`
+	ExpectedFields map[string]string `json:"expected_fields"`
+}
+
+func (a queryAssertion) check(value string, fields map[string]interface{}) error {
+	if a.ExpectedValue != nil && value != *a.ExpectedValue {
+		return fmt.Errorf("expected %q, got %q", *a.ExpectedValue, value)
+	}
+	if a.MinValue != nil || a.MaxValue != nil {
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return fmt.Errorf("result %q is not numeric", value)
+		}
+		if a.MinValue != nil && n < *a.MinValue {
+			return fmt.Errorf("result %v is below minimum %v", n, *a.MinValue)
+		}
+		if a.MaxValue != nil && n > *a.MaxValue {
+			return fmt.Errorf("result %v is above maximum %v", n, *a.MaxValue)
+		}
+	}
+	for k, want := range a.ExpectedFields {
+		got, ok := fields[k]
+		if !ok {
+			return fmt.Errorf("field %s missing from result", k)
+		}
+		if fmt.Sprintf("%v", got) != want {
+			return fmt.Errorf("field %s is %v, expected %s", k, got, want)
+		}
+	}
+	return nil
+}
+
+// postgresSSLModes are the sslmode values accepted as tls for postgres.
+var postgresSSLModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true, "require": true, "verify-ca": true, "verify-full": true,
+}
+
+func parseDatabaseConfig(m Monitor) (databaseConfig, error) {
+	var cfg databaseConfig
+	if m.Metadata != "" {
+		if err := json.Unmarshal([]byte(m.Metadata), &cfg); err != nil {
+			return cfg, fmt.Errorf("invalid %s metadata: %v", m.Type, err)
+		}
+	}
+	switch cfg.TLS {
+	case "", "true", "skip-verify":
+	default:
+		if m.Type != TypePostgres || !postgresSSLModes[cfg.TLS] {
+			return cfg, fmt.Errorf("invalid tls %q", cfg.TLS)
+		}
+	}
+	if m.Type == TypeRedis && cfg.Database != "" {
+		if n, err := strconv.Atoi(cfg.Database); err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid redis database %q", cfg.Database)
+		}
+	}
+	if cfg.MinValue != nil && cfg.MaxValue != nil && *cfg.MinValue > *cfg.MaxValue {
+		return cfg, fmt.Errorf("min_value %v is above max_value %v", *cfg.MinValue, *cfg.MaxValue)
+	}
+	return cfg, nil
+}
+
+func withDefaultPort(target, port string) string {
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		return net.JoinHostPort(target, port)
+	}
+	return target
+}
+
+func (e *Engine) checkDatabase(m Monitor) Result {
+	cfg, err := parseDatabaseConfig(m)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}
+	}
+
+	switch m.Type {
+	case TypeRedis:
+		return e.checkRedis(m, cfg)
+	case TypePostgres:
+		host, port, _ := net.SplitHostPort(withDefaultPort(m.Target, "5432"))
+		sslmode := cfg.TLS
+		switch sslmode {
+		case "":
+			sslmode = "prefer"
+		case "true":
+			sslmode = "verify-full"
+		case "skip-verify":
+			sslmode = "require"
+		}
+		u := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(cfg.Username, cfg.Password),
+			Host:     net.JoinHostPort(host, port),
+			Path:     "/" + cfg.Database,
+			RawQuery: url.Values{"sslmode": {sslmode}, "connect_timeout": {"10"}}.Encode(),
+		}
+		return e.checkSQL(m, cfg, "pgx", u.String())
+	case TypeMySQL:
+		mc := mysql.NewConfig()
+		mc.User = cfg.Username
+		mc.Passwd = cfg.Password
+		mc.Net = "tcp"
+		mc.Addr = withDefaultPort(m.Target, "3306")
+		mc.DBName = cfg.Database
+		mc.Timeout = databaseCheckTimeout
+		if cfg.TLS != "" {
+			mc.TLSConfig = cfg.TLS
+		}
+		return e.checkSQL(m, cfg, "mysql", mc.FormatDSN())
+	}
+	return Result{MonitorID: m.ID, Status: "down", Message: fmt.Sprintf("Unsupported database type %s", m.Type)}
+}
+
+func (e *Engine) checkSQL(m Monitor, cfg databaseConfig, driver, dsn string) Result {
+	ctx, cancel := context.WithTimeout(e.ctx, databaseCheckTimeout)
+	defer cancel()
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: fmt.Sprintf("Invalid connection settings: %v", err)}
+	}
+	defer db.Close()
+
+	start := time.Now()
+	if err := db.PingContext(ctx); err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Latency: int(time.Since(start).Milliseconds()), Message: fmt.Sprintf("Connection failed: %v", err)}
+	}
+	connectMs := time.Since(start).Milliseconds()
+
+	query := cfg.Query
+	if query == "" {
+		query = "SELECT 1"
+	}
+
+	start = time.Now()
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Latency: int(time.Since(start).Milliseconds()), Message: fmt.Sprintf("Query failed: %v", err)}
+	}
+	defer rows.Close()
+
+	cols, _ := rows.Columns()
+	fields := make(map[string]interface{})
+	var value string
+	rowCount := 0
+	for rows.Next() {
+		rowCount++
+		if rowCount > 1 {
+			continue
+		}
+		vals := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return Result{MonitorID: m.ID, Status: "down", Message: fmt.Sprintf("Reading result failed: %v", err)}
+		}
+		for i, col := range cols {
+			v := vals[i]
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			if t, ok := v.(time.Time); ok {
+				v = t.UTC().Format(time.RFC3339)
+			}
+			fields[col] = v
+			if i == 0 && v != nil {
+				value = fmt.Sprintf("%v", v)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: fmt.Sprintf("Query failed: %v", err)}
+	}
+	latency := int(time.Since(start).Milliseconds())
+
+	// Columns are nested so a column named like a check field cannot replace it
+	data := map[string]interface{}{
+		"connect_ms": connectMs,
+		"rows":       rowCount,
+		"columns":    fields,
+	}
+
+	if err := cfg.check(value, fields); err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: fmt.Sprintf("Assertion failed: %v", err), Data: marshalData(data)}
+	}
+	return Result{MonitorID: m.ID, Status: "up", Latency: latency, Message: fmt.Sprintf("Query OK (%d rows)", rowCount), Data: marshalData(data)}
+}
+
+func (e *Engine) checkRedis(m Monitor, cfg databaseConfig) Result {
+	ctx, cancel := context.WithTimeout(e.ctx, databaseCheckTimeout)
+	defer cancel()
+
+	opts := &redis.Options{
+		Addr:        withDefaultPort(m.Target, "6379"),
+		Username:    cfg.Username,
+		Password:    cfg.Password,
+		DialTimeout: databaseCheckTimeout,
+		ReadTimeout: databaseCheckTimeout,
+		MaxRetries:  -1,
+	}
+	if cfg.Database != "" {
+		opts.DB, _ = strconv.Atoi(cfg.Database)
+	}
+	if cfg.TLS != "" {
+		opts.TLSConfig = &tls.Config{InsecureSkipVerify: cfg.TLS == "skip-verify"}
+	}
+	client := redis.NewClient(opts)
+	defer client.Close()
+
+	command := strings.Fields(cfg.Query)
+	if len(command) == 0 {
+		command = []string{"PING"}
+	}
+	args := make([]interface{}, len(command))
+	for i, c := range command {
+		args[i] = c
+	}
+
+	start := time.Now()
+	reply, err := client.Do(ctx, args...).Result()
+	latency := int(time.Since(start).Milliseconds())
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: fmt.Sprintf("Redis %s failed: %v", command[0], err)}
+	}
+
+	value := fmt.Sprintf("%v", reply)
+	fields := make(map[string]interface{})
+	if strings.EqualFold(command[0], "INFO") {
+		// INFO replies are "key:value" lines grouped under "# Section" headers
+		for _, line := range strings.Split(value, "\n") {
+			line = strings.TrimSpace(line)
+			if k, v, ok := strings.Cut(line, ":"); ok && !strings.HasPrefix(line, "#") {
+				fields[k] = v
+			}
+		}
+	}
+
+	// INFO fields are nested like SQL columns, under "info"
+	data := map[string]interface{}{"reply": value}
+	if len(fields) > 0 {
+		data = map[string]interface{}{"info": fields}
+	}
+
+	if err := cfg.check(value, fields); err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: fmt.Sprintf("Assertion failed: %v", err), Data: marshalData(data)}
+	}
+	return Result{MonitorID: m.ID, Status: "up", Latency: latency, Message: fmt.Sprintf("Redis %s OK", strings.ToUpper(command[0])), Data: marshalData(data)}
+}
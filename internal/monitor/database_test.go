@@ -0,0 +1,60 @@
+package monitor
+
+import "testing"
+
+func TestParseDatabaseConfig(t *testing.T) {
+	tests := []struct {
+		typ      MonitorType
+		metadata string
+		ok       bool
+	}{
+		{TypePostgres, "", true},
+		{TypePostgres, `{"tls":"verify-ca","query":"SELECT 1"}`, true},
+		{TypePostgres, `{"tls":"skip-verify"}`, true},
+		{TypeMySQL, `{"tls":"true","database":"app"}`, true},
+		{TypeRedis, `{"database":"3","query":"INFO replication"}`, true},
+		{TypeRedis, `{"min_value":1,"max_value":1}`, true},
+		{TypePostgres, `{"tls":"sometimes"}`, false},
+		{TypeMySQL, `{"tls":"verify-full"}`, false},
+		{TypeRedis, `{"tls":"require"}`, false},
+		{TypeRedis, `{"database":"cache"}`, false},
+		{TypeRedis, `{"database":"-1"}`, false},
+		{TypeMySQL, `{"min_value":10,"max_value":1}`, false},
+		{TypePostgres, `{"query":`, false},
+		{TypeMySQL, `{"expected_fields":["a"]}`, false},
+	}
+	for _, tt := range tests {
+		_, err := parseDatabaseConfig(Monitor{Type: tt.typ, Metadata: tt.metadata})
+		if (err == nil) != tt.ok {
+			t.Errorf("parseDatabaseConfig(%s, %q) error = %v, want ok %v", tt.typ, tt.metadata, err, tt.ok)
+		}
+	}
+}
+
+func TestQueryAssertion(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(f float64) *float64 { return &f }
+	fields := map[string]interface{}{"role": "master", "connected_slaves": "2", "lag": 1.5}
+
+	tests := []struct {
+		a     queryAssertion
+		value string
+		ok    bool
+	}{
+		{queryAssertion{}, "anything", true},
+		{queryAssertion{ExpectedValue: str("PONG")}, "PONG", true},
+		{queryAssertion{ExpectedValue: str("PONG")}, "pong", false},
+		{queryAssertion{MinValue: num(1), MaxValue: num(30)}, " 12 ", true},
+		{queryAssertion{MinValue: num(1)}, "0.5", false},
+		{queryAssertion{MaxValue: num(30)}, "31", false},
+		{queryAssertion{MaxValue: num(30)}, "n/a", false},
+		{queryAssertion{ExpectedFields: map[string]string{"role": "master", "lag": "1.5"}}, "", true},
+		{queryAssertion{ExpectedFields: map[string]string{"role": "slave"}}, "", false},
+		{queryAssertion{ExpectedFields: map[string]string{"uptime": "1"}}, "", false},
+	}
+	for i, tt := range tests {
+		if err := tt.a.check(tt.value, fields); (err == nil) != tt.ok {
+			t.Errorf("case %d: check(%q) error = %v, want ok %v", i, tt.value, err, tt.ok)
+		}
+	}
+}
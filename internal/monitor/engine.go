@@ -2,45 +2,85 @@ package monitor
 
 import (
 	"context"
-	"crypto/md5"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net"
 	"net/http"
 	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"aeromonitor/internal/db"
 	"aeromonitor/internal/notification"
 	"aeromonitor/internal/settings"
-
-	"github.com/jmoiron/sqlx"
 )
 
 type Engine struct {
-	db         *sqlx.DB
-	settings   *settings.Service
-	monitors   map[string]*Monitor
-	status     map[string]string // monitorID -> "up"/"down"
-	lastChecks map[string]time.Time
-	httpClient *http.Client
-	mu         sync.RWMutex
-	ctx        context.Context
-	cancel     context.CancelFunc
+	db            *db.DB
+	settings      *settings.Service
+	monitors      map[string]*Monitor
+	status        map[string]string // monitorID -> "up"/"down"
+	lastChecks    map[string]time.Time
+	ruleStreaks   map[string]map[string]int // monitorID -> rule -> consecutive violations
+	httpClient    *http.Client
+	mqttSubs      map[string]*mqttSubscription
+	mqttPending   map[string]*mqttSubscription // Subscriptions still connecting
+	mqttMu        sync.Mutex
+	execAllowlist []string // Executables exec monitors may run; empty disables them
+
+	// Probe locations: location is where this engine runs checks, forward
+	// hands results to the server when running as an agent.
+	location       string
+	forward        func(Result)
+	locationStatus map[string]map[string]locationResult // monitorID -> location -> latest
+	lastAggregate  map[string]time.Time
+
+	// High availability: only the leader schedules checks and notifies.
+	// The sync tails follow heartbeats and location votes written by other
+	// instances.
+	isLeader      func() bool
+	wasLeading    bool
+	heartbeatTail *syncTail
+	locationTail  *syncTail
+
+	// Heartbeats are queued and written in batches by heartbeatWriter; latest
+	// holds the newest heartbeat of each monitor for the dashboard.
+	latest     map[string]Heartbeat
+	pending    []Heartbeat
+	pendingMu  sync.Mutex
+	flushMu    sync.Mutex
+	flushNow   chan struct{}
+	writerDone chan struct{}
+
+	// Raw heartbeat retention; rollups are kept forever
+	retention     time.Duration
+	lastRollup    time.Time
+	rollupRunning atomic.Bool
+
+	mu     sync.RWMutex
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-func NewEngine(db *sqlx.DB, s *settings.Service) *Engine {
+func NewEngine(database *db.DB, s *settings.Service) *Engine {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Engine{
-		db:         db,
-		settings:   s,
-		monitors:   make(map[string]*Monitor),
-		status:     make(map[string]string),
-		lastChecks: make(map[string]time.Time),
+		db:          database,
+		settings:    s,
+		monitors:    make(map[string]*Monitor),
+		status:      make(map[string]string),
+		lastChecks:  make(map[string]time.Time),
+		ruleStreaks: make(map[string]map[string]int),
+		latest:      make(map[string]Heartbeat),
+		flushNow:    make(chan struct{}, 1),
+		mqttSubs:    make(map[string]*mqttSubscription),
+		mqttPending: make(map[string]*mqttSubscription),
+
+		location:       localLocation,
+		locationStatus: make(map[string]map[string]locationResult),
+		lastAggregate:  make(map[string]time.Time),
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
@@ -52,17 +92,21 @@ func NewEngine(db *sqlx.DB, s *settings.Service) *Engine {
 func (e *Engine) Start() {
 	log.Println("Monitoring engine starting...")
 	e.loadInitialStatus()
+	e.loadLocationStatus()
+	if e.isLeader != nil {
+		e.heartbeatTail = newSyncTail()
+		e.locationTail = newSyncTail()
+	}
+	e.writerDone = make(chan struct{})
+	go e.heartbeatWriter()
 	go e.scheduler()
 }
 
 func (e *Engine) loadInitialStatus() {
-	var results []struct {
-		MonitorID string `db:"monitor_id"`
-		Status    string `db:"status"`
-	}
-	// Fetch the latest status for each monitor
+	var results []Heartbeat
+	// Fetch the latest heartbeat for each monitor
 	query := `
-		SELECT h1.monitor_id, h1.status 
+		SELECT h1.id, h1.monitor_id, h1.status, h1.latency, COALESCE(h1.message, '') as message, COALESCE(h1.data, '') as data, h1.timestamp
 		FROM heartbeats h1
 		JOIN (
 			SELECT monitor_id, MAX(timestamp) as max_ts 
@@ -80,12 +124,28 @@ func (e *Engine) loadInitialStatus() {
 	defer e.mu.Unlock()
 	for _, r := range results {
 		e.status[r.MonitorID] = r.Status
+		e.latest[r.MonitorID] = r
 	}
 	log.Printf("Loaded initial status for %d monitors", len(results))
 }
 
+// SetLeadership makes the engine schedule checks and send notifications only
+// while isLeader reports true, for several instances sharing one database.
+// It must be called before Start.
+func (e *Engine) SetLeadership(isLeader func() bool) {
+	e.isLeader = isLeader
+}
+
+func (e *Engine) leading() bool {
+	return e.isLeader == nil || e.isLeader()
+}
+
+// Stop stops the engine once the queued heartbeats are written.
 func (e *Engine) Stop() {
 	e.cancel()
+	if e.writerDone != nil {
+		<-e.writerDone
+	}
 }
 
 func (e *Engine) scheduler() {
@@ -97,7 +157,23 @@ func (e *Engine) scheduler() {
 		case <-e.ctx.Done():
 			return
 		case <-ticker.C:
+			if e.isLeader != nil {
+				e.syncStatus()
+			}
+			leading := e.leading()
+			if !leading {
+				if e.wasLeading {
+					e.stepDown()
+				}
+				e.wasLeading = false
+				continue
+			}
+			e.wasLeading = true
+			// Checks see the results written up to this tick
+			e.flushHeartbeats()
 			e.runChecks()
+			e.flushNotificationQueue()
+			e.scheduleRollups()
 		}
 	}
 }
@@ -110,12 +186,22 @@ func (e *Engine) runChecks() {
 		return
 	}
 
+	activeSubs := make(map[string]bool)
 	for _, m := range monitors {
 		if m.Paused {
 			continue
 		}
+		if cfg, _ := parseLocationConfig(m.Metadata); !cfg.includes(e.location) {
+			// Checked by probe agents elsewhere
+			go e.checkLocationsReporting(m, cfg)
+			continue
+		}
 		if m.Type == TypePush {
 			go e.checkPushTimeout(m)
+		} else if m.Type == TypeMQTT && isMQTTSubscription(m) {
+			// Subscriptions are passive like push monitors
+			activeSubs[m.ID] = true
+			go e.checkMQTTSubscription(m)
 		} else {
 			e.mu.Lock()
 			lastRun, ok := e.lastChecks[m.ID]
@@ -137,36 +223,8 @@ func (e *Engine) runChecks() {
 			}
 		}
 	}
-}
-
-func (e *Engine) checkPushTimeout(m Monitor) {
-	var lastTime time.Time
-	err := e.db.Get(&lastTime, "SELECT timestamp FROM heartbeats WHERE monitor_id = ? ORDER BY timestamp DESC LIMIT 1", m.ID)
-
-	isTimeout := false
-	if err != nil {
-		isTimeout = true
-	} else {
-		since := time.Now().UTC().Sub(lastTime)
-		if since > (time.Duration(m.Interval)*time.Second + 5*time.Second) {
-			isTimeout = true
-		}
-	}
-
-	if isTimeout && !m.Paused {
-		e.mu.RLock()
-		currentStatus := e.status[m.ID]
-		e.mu.RUnlock()
-
-		if currentStatus != "down" {
-			e.saveResult(Result{
-				MonitorID: m.ID,
-				Status:    "down",
-				Message:   "Heartbeat timeout",
-				Latency:   0,
-			})
-		}
-	}
+	e.pruneMQTTSubscriptions(activeSubs)
+	e.pruneRuleStreaks(monitors)
 }
 
 func (e *Engine) checkMonitor(m Monitor) {
@@ -177,10 +235,35 @@ func (e *Engine) checkMonitor(m Monitor) {
 		result = e.checkHTTP(m)
 	case TypeTCP:
 		result = e.checkTCP(m)
+	case TypeUDP:
+		result = e.checkUDP(m)
 	case TypePing:
 		result = e.checkPing(m)
 	case TypeFileUpdate:
 		result = e.checkFileUpdate(m)
+	case TypeMQTT:
+		result = e.checkMQTT(m)
+	case TypePostgres, TypeMySQL, TypeRedis:
+		result = e.checkDatabase(m)
+	case TypeGRPC:
+		result = e.checkGRPC(m)
+	case TypeNTRIP:
+		result = e.checkNTRIP(m)
+	case TypeSSH:
+		result = e.checkSSH(m)
+	case TypeExec:
+		result = e.checkExec(m)
+	case TypeHTTPFlow:
+		result = e.checkHTTPFlow(m)
+	case TypeGroup:
+		var reported bool
+		if result, reported = e.checkGroup(m); !reported {
+			return
+		}
+	case TypePrometheus:
+		result = e.checkPrometheus(m)
+	case TypeWebSocket:
+		result = e.checkWebSocket(m)
 	case TypePush:
 		// Push monitors are passive, they don't run active checks
 		return
@@ -189,12 +272,22 @@ func (e *Engine) checkMonitor(m Monitor) {
 		return
 	}
 
-	e.saveResult(result)
+	e.applyRules(m, &result)
+	e.recordResult(m, result)
 }
 
 func (e *Engine) checkHTTP(m Monitor) Result {
+	client, err := e.monitorHTTPClient(m)
+	if err != nil {
+		return Result{
+			MonitorID: m.ID,
+			Status:    "down",
+			Message:   err.Error(),
+		}
+	}
+
 	start := time.Now().UTC()
-	resp, err := e.httpClient.Get(m.Target)
+	resp, err := client.Get(m.Target)
 	latency := int(time.Since(start).Milliseconds())
 
 	if err != nil {
@@ -225,8 +318,28 @@ func (e *Engine) checkHTTP(m Monitor) Result {
 }
 
 func (e *Engine) checkTCP(m Monitor) Result {
+	cfg, err := parseProbeConfig(m.Metadata)
+	if err != nil {
+		return Result{
+			MonitorID: m.ID,
+			Status:    "down",
+			Message:   err.Error(),
+		}
+	}
+
+	dial, err := monitorDialer(m, cfg.timeout())
+	if err != nil {
+		return Result{
+			MonitorID: m.ID,
+			Status:    "down",
+			Message:   err.Error(),
+		}
+	}
+	ctx, cancel := context.WithTimeout(e.ctx, cfg.timeout())
+	defer cancel()
+
 	start := time.Now().UTC()
-	conn, err := net.DialTimeout("tcp", m.Target, 5*time.Second)
+	conn, err := dial(ctx, "tcp", m.Target)
 	latency := int(time.Since(start).Milliseconds())
 
 	if err != nil {
@@ -239,11 +352,35 @@ func (e *Engine) checkTCP(m Monitor) Result {
 	}
 	defer conn.Close()
 
+	if cfg.Send == "" && cfg.SendHex == "" && !cfg.expectsResponse() {
+		return Result{
+			MonitorID: m.ID,
+			Status:    "up",
+			Latency:   latency,
+			Message:   "TCP Connection Successful",
+		}
+	}
+
+	// Protocol handshake: send the payload and match the response
+	resp, err := cfg.exchange(conn)
+	latency = int(time.Since(start).Milliseconds())
+	data := marshalData(map[string]interface{}{"response": describeResponse(resp)})
+	if err != nil {
+		return Result{
+			MonitorID: m.ID,
+			Status:    "down",
+			Latency:   latency,
+			Message:   err.Error(),
+			Data:      data,
+		}
+	}
+
 	return Result{
 		MonitorID: m.ID,
 		Status:    "up",
 		Latency:   latency,
-		Message:   "TCP Connection Successful",
+		Message:   "TCP response matched",
+		Data:      data,
 	}
 }
 
@@ -276,160 +413,46 @@ func (e *Engine) checkPing(m Monitor) Result {
 	}
 }
 
-func (e *Engine) checkFileUpdate(m Monitor) Result {
-	start := time.Now().UTC()
-
-	// 1. Parse Metadata for Expected Interval
-	expectedInterval := 15 * time.Minute // Default
-	var metadata struct {
-		ExpectedIntervalMinutes int `json:"expected_update_interval"`
-	}
-	if err := json.Unmarshal([]byte(m.Metadata), &metadata); err == nil && metadata.ExpectedIntervalMinutes > 0 {
-		expectedInterval = time.Duration(metadata.ExpectedIntervalMinutes) * time.Minute
-	}
-
-	// 2. Fetch Last Heartbeat to get history
-	var lastDataStr string
-	var currentMD5 string
-
-	// Get the last successful check's data
-	e.db.Get(&lastDataStr, "SELECT data FROM heartbeats WHERE monitor_id = ? AND status = 'up' ORDER BY timestamp DESC LIMIT 1", m.ID)
-
-	var lastData struct {
-		CurrentMD5  string `json:"current_md5"`
-		LastChanged string `json:"last_changed"`
-	}
-	if lastDataStr != "" {
-		json.Unmarshal([]byte(lastDataStr), &lastData)
-	}
-
-	// 3. Download File
-	req, err := http.NewRequest("GET", m.Target, nil)
-	if err != nil {
-		return Result{
-			MonitorID: m.ID,
-			Status:    "down",
-			Latency:   0,
-			Message:   fmt.Sprintf("Request creation failed: %v", err),
-		}
-	}
-
-	// Add Basic Auth if configured
-	var authData struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-	}
-	if err := json.Unmarshal([]byte(m.Metadata), &authData); err == nil {
-		if authData.Username != "" || authData.Password != "" {
-			req.SetBasicAuth(authData.Username, authData.Password)
-		}
-	}
-
-	resp, err := e.httpClient.Do(req)
-	if err != nil {
-		return Result{
-			MonitorID: m.ID,
-			Status:    "down",
-			Latency:   0,
-			Message:   fmt.Sprintf("Download failed: %v", err),
-		}
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return Result{
-			MonitorID: m.ID,
-			Status:    "down",
-			Latency:   0,
-			Message:   fmt.Sprintf("HTTP Error: %s", resp.Status),
-		}
-	}
-
-	// 4. Calculate MD5
-	hash := md5.New()
-	if _, err := io.Copy(hash, resp.Body); err != nil {
-		return Result{
-			MonitorID: m.ID,
-			Status:    "down",
-			Latency:   0,
-			Message:   fmt.Sprintf("Hashing failed: %v", err),
-		}
-	}
-	currentMD5 = hex.EncodeToString(hash.Sum(nil))
-	latency := int(time.Since(start).Milliseconds())
-
-	// 5. Determine State
-	now := time.Now().UTC()
-	lastChangedTime := now // Default if new
-	if lastData.LastChanged != "" {
-		if t, err := time.Parse(time.RFC3339, lastData.LastChanged); err == nil {
-			lastChangedTime = t
-		}
-	}
-
-	status := "up"
-	message := "File is fresh"
-
-	if currentMD5 != lastData.CurrentMD5 {
-		// File Changed! Update last changed time to NOW
-		lastChangedTime = now
-		message = "File updated recently"
-	} else {
-		// File NOT Changed. Check if it's stale
-		// If we don't have a previous record (lastData.CurrentMD5 is empty), we consider it fresh as baseline
-		if lastData.CurrentMD5 != "" {
-			if now.Sub(lastChangedTime) > expectedInterval {
-				status = "down"
-				message = fmt.Sprintf("File stale! Not updated in %s", now.Sub(lastChangedTime).Round(time.Minute))
-			} else {
-				message = fmt.Sprintf("File valid. Last update: %s ago", now.Sub(lastChangedTime).Round(time.Minute))
-			}
-		} else {
-			message = "New file monitoring started"
-		}
-	}
-
-	// Prepare result data
-	resultData := map[string]string{
-		"current_md5":  currentMD5,
-		"last_changed": lastChangedTime.Format(time.RFC3339),
-	}
-	dataBytes, _ := json.Marshal(resultData)
-
-	return Result{
-		MonitorID: m.ID,
-		Status:    status,
-		Latency:   latency,
-		Message:   message,
-		Data:      string(dataBytes),
-	}
-}
-
 func (e *Engine) saveResult(res Result) {
-	_, err := e.db.Exec(`
-		INSERT INTO heartbeats (monitor_id, status, latency, message, data, timestamp)
-		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
-	`, res.MonitorID, res.Status, res.Latency, res.Message, res.Data)
-	if err != nil {
-		log.Printf("Error saving heartbeat: %v", err)
-	}
+	h := Heartbeat{
+		MonitorID: res.MonitorID,
+		Status:    res.Status,
+		Latency:   res.Latency,
+		Message:   res.Message,
+		Data:      res.Data,
+		Timestamp: time.Now().UTC(),
+	}
+	e.queueHeartbeat(h)
+	e.setLatest(h)
+	if e.forward != nil {
+		e.forward(res)
+	}
+	e.applyStatus(res.MonitorID, res.Status)
+}
 
-	// Update current status and trigger notifications
+// applyStatus updates the current status of a monitor and triggers the
+// notifications. Followers only track the status; the leader acts on changes.
+func (e *Engine) applyStatus(monitorID, status string) {
 	e.mu.Lock()
-	oldStatus := e.status[res.MonitorID]
-	e.status[res.MonitorID] = res.Status
+	oldStatus := e.status[monitorID]
+	e.status[monitorID] = status
 	e.mu.Unlock()
 
+	if oldStatus == status || !e.leading() {
+		return
+	}
+
+	// Groups follow their children without waiting for their own interval
+	go e.updateParentGroups(monitorID)
+
 	// Notify if status changed
 	// We allow notification if oldStatus is empty (new monitor or first run) ONLY if new status is DOWN.
 	// We prevent noise by silencing the initial "Unknown -> Up" transition.
-	if oldStatus != res.Status {
-		if oldStatus == "" && res.Status == "up" {
-			// Silent success on startup / first run
-			return
-		}
-		e.notifyStatusChange(res.MonitorID, res.Status)
+	if oldStatus == "" && status == "up" {
+		// Silent success on startup / first run
+		return
 	}
+	e.notifyStatusChange(monitorID, status)
 }
 
 func (e *Engine) getAppTitle() string {
@@ -457,18 +480,34 @@ func (e *Engine) notifyStatusChange(monitorID string, status string) {
 	}
 
 	var notifications []struct {
-		Type   string `db:"type"`
-		Config string `db:"config"`
+		ID       string `db:"id"`
+		Type     string `db:"type"`
+		Config   string `db:"config"`
+		Schedule string `db:"schedule"`
 	}
 	query := `
-		SELECT n.type, n.config 
+		SELECT n.id, n.type, n.config, COALESCE(n.schedule, '') as schedule
 		FROM notifications n
 		JOIN monitor_notifications mn ON n.id = mn.notification_id
 		WHERE mn.monitor_id = ?
 	`
 	e.db.Select(&notifications, query, monitorID)
 
+	tags := monitorTags(m)
+	now := time.Now().UTC()
+
 	for _, n := range notifications {
+		// Respect channel quiet hours / delivery windows
+		schedule, err := notification.ParseSchedule(n.Schedule)
+		if err != nil {
+			log.Printf("Ignoring invalid schedule on notification %s: %v", n.ID, err)
+		} else if !schedule.Allows(now, tags) {
+			if schedule.QueueSuppressed() {
+				e.queueNotification(n.ID, title, message)
+			}
+			continue
+		}
+
 		extra := map[string]string{
 			"link": m.Target,
 			"info": fmt.Sprintf("Type: %s, Interval: %ds", m.Type, m.Interval),
@@ -481,3 +520,12 @@ func (e *Engine) notifyStatusChange(monitorID string, status string) {
 		}(n.Type, n.Config)
 	}
 }
+
+// monitorTags returns the tags configured in the monitor metadata.
+func monitorTags(m Monitor) []string {
+	var metadata struct {
+		Tags []string `json:"tags"`
+	}
+	json.Unmarshal([]byte(m.Metadata), &metadata)
+	return metadata.Tags
+}
@@ -0,0 +1,320 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const execDefaultTimeout = 30 * time.Second
+
+// Nagios plugin exit codes
+const (
+	nagiosOK       = 0
+	nagiosWarning  = 1
+	nagiosCritical = 2
+	nagiosUnknown  = 3
+)
+
+var nagiosStates = map[int]string{
+	nagiosOK:       "OK",
+	nagiosWarning:  "WARNING",
+	nagiosCritical: "CRITICAL",
+	nagiosUnknown:  "UNKNOWN",
+}
+
+// execConfig is the metadata of exec monitors. The command is run directly,
+// without a shell:
+//
+//	{"command": "/usr/lib/nagios/plugins/check_disk", "args": ["-w", "20%", "-c", "10%", "-p", "/"]}
+type execConfig struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env"`
+	Timeout int               `json:"timeout"` // seconds
+}
+
+func (cfg execConfig) timeout() time.Duration {
+	if cfg.Timeout > 0 {
+		return time.Duration(cfg.Timeout) * time.Second
+	}
+	return execDefaultTimeout
+}
+
+// execReservedEnv are variables a monitor may not set: they change which code
+// the allowlisted plugin loads or runs (dynamic loader, interpreter startup
+// files), which would bypass the allowlist.
+var execReservedEnv = map[string]bool{
+	"PATH": true, "IFS": true, "ENV": true, "BASH_ENV": true, "SHELLOPTS": true, "BASHOPTS": true,
+	"GCONV_PATH": true, "LOCPATH": true, "NLSPATH": true, "HOSTALIASES": true, "RESOLV_HOST_CONF": true,
+	"PERL5LIB": true, "PERL5OPT": true, "PERLLIB": true, "PYTHONPATH": true, "PYTHONHOME": true,
+	"PYTHONSTARTUP": true, "RUBYLIB": true, "RUBYOPT": true, "NODE_OPTIONS": true, "NODE_PATH": true,
+	"LUA_PATH": true, "LUA_CPATH": true, "TCLLIBPATH": true,
+}
+
+// execReservedPrefixes are reserved variable prefixes (ld.so, dyld, glibc malloc hooks).
+var execReservedPrefixes = []string{"LD_", "DYLD_", "MALLOC_", "GLIBC_"}
+
+func validateExecEnv(env map[string]string) error {
+	for k := range env {
+		if k == "" || strings.ContainsAny(k, "=\x00") {
+			return fmt.Errorf("invalid environment variable name %q", k)
+		}
+		upper := strings.ToUpper(k)
+		if execReservedEnv[upper] {
+			return fmt.Errorf("environment variable %s may not be set by exec monitors", k)
+		}
+		for _, prefix := range execReservedPrefixes {
+			if strings.HasPrefix(upper, prefix) {
+				return fmt.Errorf("environment variable %s may not be set by exec monitors", k)
+			}
+		}
+	}
+	return nil
+}
+
+// AllowExec enables exec monitors for executables matching one of the patterns
+// (absolute paths, optionally with filepath.Match wildcards). Exec monitors are
+// rejected unless this is called.
+func (e *Engine) AllowExec(patterns []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.execAllowlist = nil
+	for _, p := range patterns {
+		if p = strings.TrimSpace(p); p != "" {
+			e.execAllowlist = append(e.execAllowlist, p)
+		}
+	}
+}
+
+// parseExecConfig resolves the configured command and checks it against the
+// allowlist.
+func (e *Engine) parseExecConfig(metadata string) (execConfig, string, error) {
+	var cfg execConfig
+	json.Unmarshal([]byte(metadata), &cfg)
+	if cfg.Command == "" {
+		return cfg, "", errors.New("exec monitor requires a command")
+	}
+	if err := validateExecEnv(cfg.Env); err != nil {
+		return cfg, "", err
+	}
+
+	e.mu.RLock()
+	allowlist := e.execAllowlist
+	e.mu.RUnlock()
+	if len(allowlist) == 0 {
+		return cfg, "", errors.New("exec monitors are disabled")
+	}
+
+	path, err := exec.LookPath(cfg.Command)
+	if err != nil {
+		return cfg, "", fmt.Errorf("command not found: %s", cfg.Command)
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return cfg, "", err
+	}
+	// Resolve symlinks so an allowed directory cannot be used to reach others
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	for _, pattern := range allowlist {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return cfg, path, nil
+		}
+	}
+	return cfg, "", fmt.Errorf("command %s is not in the exec allowlist", path)
+}
+
+// checkExec runs a local command and maps its exit code like a Nagios plugin:
+// OK is up, WARNING is degraded, CRITICAL and UNKNOWN are down.
+func (e *Engine) checkExec(m Monitor) Result {
+	cfg, path, err := e.parseExecConfig(m.Metadata)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}
+	}
+
+	ctx, cancel := context.WithTimeout(e.ctx, cfg.timeout())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, cfg.Args...)
+	// Plugins get a minimal environment, not the server's secrets
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "LANG=C"}
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	var stdout, stderr limitedBuffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Don't wait for grandchildren still holding the output pipes after a kill
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err = cmd.Run()
+	latency := int(time.Since(start).Milliseconds())
+
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		switch {
+		case ctx.Err() == context.DeadlineExceeded:
+			return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: fmt.Sprintf("UNKNOWN: timed out after %s", cfg.timeout())}
+		case errors.As(err, &exitErr):
+			exitCode = exitErr.ExitCode()
+		default:
+			return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: fmt.Sprintf("UNKNOWN: %v", err)}
+		}
+	}
+
+	text, perfdata := parseNagiosOutput(stdout.String())
+	data := map[string]interface{}{"exit_code": exitCode}
+	state, ok := nagiosStates[exitCode]
+	if !ok {
+		state = nagiosStates[nagiosUnknown]
+	}
+	data["state"] = state
+	// Perfdata is nested so a label named like a check field cannot replace it
+	values := make(map[string]interface{})
+	thresholds := make(map[string]interface{})
+	for _, p := range perfdata {
+		values[p.Label] = p.Value
+		if t := p.thresholds(); len(t) > 0 {
+			thresholds[p.Label] = t
+		}
+	}
+	if len(values) > 0 {
+		data["perfdata"] = values
+	}
+	if len(thresholds) > 0 {
+		data["perfdata_thresholds"] = thresholds
+	}
+
+	if text == "" {
+		text = firstLine(stderr.String())
+	}
+	if text == "" {
+		text = state
+	}
+
+	status := "down"
+	switch exitCode {
+	case nagiosOK:
+		status = "up"
+	case nagiosWarning:
+		status = "degraded"
+	}
+	return Result{MonitorID: m.ID, Status: status, Latency: latency, Message: text, Data: marshalData(data)}
+}
+
+// perfValue is one Nagios performance data item:
+// 'label'=value[UOM];[warn];[crit];[min];[max]
+type perfValue struct {
+	Label string
+	Value float64
+	UOM   string
+	Warn  string
+	Crit  string
+	Min   string
+	Max   string
+}
+
+func (p perfValue) thresholds() map[string]interface{} {
+	t := make(map[string]interface{})
+	for k, v := range map[string]string{"uom": p.UOM, "warn": p.Warn, "crit": p.Crit, "min": p.Min, "max": p.Max} {
+		if v != "" {
+			t[k] = v
+		}
+	}
+	return t
+}
+
+// parseNagiosOutput splits plugin output into the status text (first line,
+// before "|") and the performance data, which may follow "|" on the first line
+// and on any line of the long output after a second "|".
+func parseNagiosOutput(output string) (string, []perfValue) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	text, perf, _ := strings.Cut(lines[0], "|")
+
+	var perfText []string
+	perfText = append(perfText, perf)
+	inPerf := false
+	for _, line := range lines[1:] {
+		if !inPerf {
+			_, after, found := strings.Cut(line, "|")
+			if !found {
+				continue
+			}
+			inPerf = true
+			line = after
+		}
+		perfText = append(perfText, line)
+	}
+
+	var values []perfValue
+	for _, item := range splitPerfdata(strings.Join(perfText, " ")) {
+		if v, ok := parsePerfValue(item); ok {
+			values = append(values, v)
+		}
+	}
+	return strings.TrimSpace(text), values
+}
+
+// splitPerfdata splits on whitespace outside single-quoted labels.
+func splitPerfdata(s string) []string {
+	var items []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case (r == ' ' || r == '\t' || r == '\n' || r == '\r') && !quoted:
+			if cur.Len() > 0 {
+				items = append(items, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		items = append(items, cur.String())
+	}
+	return items
+}
+
+func parsePerfValue(item string) (perfValue, bool) {
+	eq := strings.LastIndex(item, "=")
+	if eq <= 0 {
+		return perfValue{}, false
+	}
+	label := strings.Trim(item[:eq], "'")
+	label = strings.ReplaceAll(label, "''", "'")
+	parts := strings.Split(item[eq+1:], ";")
+
+	raw := parts[0]
+	i := 0
+	for i < len(raw) && (raw[i] >= '0' && raw[i] <= '9' || raw[i] == '.' || raw[i] == '-' || raw[i] == '+' || raw[i] == 'e' || raw[i] == 'E') {
+		i++
+	}
+	value, err := strconv.ParseFloat(raw[:i], 64)
+	if err != nil || label == "" {
+		return perfValue{}, false
+	}
+
+	p := perfValue{Label: label, Value: value, UOM: raw[i:]}
+	fields := []*string{&p.Warn, &p.Crit, &p.Min, &p.Max}
+	for j, f := range fields {
+		if j+1 < len(parts) {
+			*f = parts[j+1]
+		}
+	}
+	return p, true
+}
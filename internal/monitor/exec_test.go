@@ -0,0 +1,120 @@
+package monitor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParsePerfValue(t *testing.T) {
+	tests := []struct {
+		item string
+		want perfValue
+	}{
+		{"time=0.012s", perfValue{Label: "time", Value: 0.012, UOM: "s"}},
+		{"load1=0.52;4;8;0", perfValue{Label: "load1", Value: 0.52, Warn: "4", Crit: "8", Min: "0"}},
+		{"'/var'=1024MB;;;0;2048", perfValue{Label: "/var", Value: 1024, UOM: "MB", Min: "0", Max: "2048"}},
+		{"'it''s'=5", perfValue{Label: "it's", Value: 5}},
+		{"'a=b'=-1.5e3%;@10:20", perfValue{Label: "a=b", Value: -1500, UOM: "%", Warn: "@10:20"}},
+		{"rta=0.1ms;100.0;500.0;0;", perfValue{Label: "rta", Value: 0.1, UOM: "ms", Warn: "100.0", Crit: "500.0", Min: "0"}},
+	}
+	for _, tt := range tests {
+		got, ok := parsePerfValue(tt.item)
+		if !ok {
+			t.Errorf("parsePerfValue(%q): not parsed", tt.item)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parsePerfValue(%q) = %+v, want %+v", tt.item, got, tt.want)
+		}
+	}
+	for _, item := range []string{"", "novalue", "=5", "''=5", "time=", "time=U", "time=fast"} {
+		if v, ok := parsePerfValue(item); ok {
+			t.Errorf("parsePerfValue(%q) = %+v, expected no value", item, v)
+		}
+	}
+}
+
+func TestParseNagiosOutput(t *testing.T) {
+	tests := []struct {
+		output string
+		text   string
+		labels []string
+	}{
+		{"", "", nil},
+		{"OK - all good", "OK - all good", nil},
+		{"DISK OK - free space: / 3326 MB (56%); | /=2643MB;5948;5958;0;5968\n",
+			"DISK OK - free space: / 3326 MB (56%);", []string{"/"}},
+		{"PING OK | rta=0.1ms;100;500 pl=0%;20;60", "PING OK", []string{"rta", "pl"}},
+		{"DISK OK | /=2643MB\n/ 15272 MB (77%);\n/boot 68 MB (69%);\n/home 69357 MB (27%) | /boot=68MB;88;93;0;98\n/home=69357MB;253404;253409;0;253414",
+			"DISK OK", []string{"/", "/boot", "/home"}},
+		{"PROCS OK | 'total procs'=42 'zombie procs'=0", "PROCS OK", []string{"total procs", "zombie procs"}},
+		{"WARNING | broken=abc ok=1", "WARNING", []string{"ok"}},
+	}
+	for _, tt := range tests {
+		text, perfdata := parseNagiosOutput(tt.output)
+		var labels []string
+		for _, p := range perfdata {
+			labels = append(labels, p.Label)
+		}
+		if text != tt.text || !reflect.DeepEqual(labels, tt.labels) {
+			t.Errorf("parseNagiosOutput(%q) = %q %q, want %q %q", tt.output, text, labels, tt.text, tt.labels)
+		}
+	}
+}
+
+func TestCheckExec(t *testing.T) {
+	dir := t.TempDir()
+	plugin := filepath.Join(dir, "check_fake")
+	script := "#!/bin/sh\necho \"DISK WARNING - 85% used | exit_code=7 state=ok '/'=85%;80;90\"\nexit $1\n"
+	if err := os.WriteFile(plugin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine(nil, nil)
+	defer e.Stop()
+	e.AllowExec([]string{filepath.Join(dir, "*")})
+
+	tests := []struct {
+		exitCode int
+		status   string
+		state    string
+	}{
+		{0, "up", "OK"},
+		{1, "degraded", "WARNING"},
+		{2, "down", "CRITICAL"},
+		{3, "down", "UNKNOWN"},
+		{9, "down", "UNKNOWN"},
+	}
+	for _, tt := range tests {
+		metadata := marshalData(map[string]interface{}{"command": plugin, "args": []string{strconv.Itoa(tt.exitCode)}})
+		r := e.checkExec(Monitor{ID: "m1", Metadata: metadata})
+		if r.Status != tt.status || r.Message != "DISK WARNING - 85% used" {
+			t.Errorf("exit %d: %s %q, want %s", tt.exitCode, r.Status, r.Message, tt.status)
+		}
+		var data struct {
+			ExitCode   int                               `json:"exit_code"`
+			State      string                            `json:"state"`
+			Perfdata   map[string]float64                `json:"perfdata"`
+			Thresholds map[string]map[string]interface{} `json:"perfdata_thresholds"`
+		}
+		if err := json.Unmarshal([]byte(r.Data), &data); err != nil {
+			t.Fatal(err)
+		}
+		// Perfdata labels named like check fields stay under perfdata
+		wantPerf := map[string]float64{"exit_code": 7, "/": 85}
+		if data.ExitCode != tt.exitCode || data.State != tt.state || !reflect.DeepEqual(data.Perfdata, wantPerf) {
+			t.Errorf("exit %d: data %s", tt.exitCode, r.Data)
+		}
+		if data.Thresholds["/"]["warn"] != "80" || data.Thresholds["/"]["uom"] != "%" {
+			t.Errorf("exit %d: thresholds %v", tt.exitCode, data.Thresholds)
+		}
+	}
+
+	e.AllowExec(nil)
+	if r := e.checkExec(Monitor{ID: "m1", Metadata: marshalData(map[string]interface{}{"command": plugin})}); r.Message != "exec monitors are disabled" {
+		t.Errorf("without an allowlist: %s %q", r.Status, r.Message)
+	}
+}
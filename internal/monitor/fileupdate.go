@@ -0,0 +1,563 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// File change detection modes
+const (
+	fileDetectHash     = "hash"     // Download and hash the content (default)
+	fileDetectMetadata = "metadata" // Compare ETag / modification time / size only
+)
+
+// fileUpdateConfig is the metadata of file_update monitors. The target is an
+// http(s):// URL, a local path (absolute or file://), sftp://host[:port]/path
+// or s3://bucket/key.
+type fileUpdateConfig struct {
+	ExpectedIntervalMinutes int    `json:"expected_update_interval"`
+	Detection               string `json:"detection"`
+
+	// Credentials: basic auth (HTTP), login (SFTP)
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	// SFTP
+	PrivateKey            string `json:"private_key"`
+	Passphrase            string `json:"passphrase"`
+	HostKeyFingerprint    string `json:"host_key_fingerprint"`
+	InsecureIgnoreHostKey bool   `json:"insecure_ignore_host_key"`
+
+	// S3-compatible storage
+	Endpoint   string `json:"endpoint"` // host[:port], default s3.amazonaws.com
+	Region     string `json:"region"`
+	AccessKey  string `json:"access_key"`
+	SecretKey  string `json:"secret_key"`
+	DisableTLS bool   `json:"disable_tls"`
+
+	// Bounds and content checks; content checks require a download
+	MinSize      *int64 `json:"min_size"` // bytes
+	MaxSize      *int64 `json:"max_size"`
+	MinLines     *int   `json:"min_lines"`
+	MaxLines     *int   `json:"max_lines"`
+	Contains     string `json:"contains"`
+	ContainsDate string `json:"contains_date"` // Go layout of today's date, e.g. "2006-01-02"
+	Timezone     string `json:"timezone"`      // For contains_date, default UTC
+}
+
+func parseFileUpdateConfig(m Monitor) (fileUpdateConfig, error) {
+	var cfg fileUpdateConfig
+	json.Unmarshal([]byte(m.Metadata), &cfg)
+	switch cfg.Detection {
+	case "":
+		cfg.Detection = fileDetectHash
+	case fileDetectHash, fileDetectMetadata:
+	default:
+		return cfg, fmt.Errorf("invalid detection %q", cfg.Detection)
+	}
+	if cfg.Timezone != "" {
+		if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+			return cfg, fmt.Errorf("invalid timezone: %v", err)
+		}
+	}
+	if strings.HasPrefix(m.Target, "sftp://") && cfg.HostKeyFingerprint == "" && !cfg.InsecureIgnoreHostKey {
+		return cfg, errors.New("sftp targets require host_key_fingerprint")
+	}
+	return cfg, nil
+}
+
+func (cfg fileUpdateConfig) expectedInterval() time.Duration {
+	if cfg.ExpectedIntervalMinutes > 0 {
+		return time.Duration(cfg.ExpectedIntervalMinutes) * time.Minute
+	}
+	return 15 * time.Minute
+}
+
+func (cfg fileUpdateConfig) needsContent() bool {
+	return cfg.Detection == fileDetectHash || cfg.MinLines != nil || cfg.MaxLines != nil ||
+		cfg.Contains != "" || cfg.ContainsDate != ""
+}
+
+// needles returns the strings the content must contain.
+func (cfg fileUpdateConfig) needles() []string {
+	var needles []string
+	if cfg.Contains != "" {
+		needles = append(needles, cfg.Contains)
+	}
+	if cfg.ContainsDate != "" {
+		loc := time.UTC
+		if cfg.Timezone != "" {
+			loc, _ = time.LoadLocation(cfg.Timezone)
+		}
+		needles = append(needles, time.Now().In(loc).Format(cfg.ContainsDate))
+	}
+	return needles
+}
+
+// fileStat is what a source knows about a file without reading it. Size is -1
+// when unknown.
+type fileStat struct {
+	Size    int64
+	ModTime time.Time
+	ETag    string
+}
+
+// fileSource is a place file_update monitors can read from.
+type fileSource interface {
+	Stat(ctx context.Context) (fileStat, error)
+	Open(ctx context.Context) (io.ReadCloser, fileStat, error)
+	Close() error
+}
+
+func (e *Engine) newFileSource(m Monitor, cfg fileUpdateConfig) (fileSource, error) {
+	if strings.HasPrefix(m.Target, "/") {
+		return localFileSource{path: m.Target}, nil
+	}
+	u, err := url.Parse(m.Target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target: %v", err)
+	}
+	network, err := parseNetworkConfig(m.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		client, err := e.monitorHTTPClient(m)
+		if err != nil {
+			return nil, err
+		}
+		return &httpFileSource{client: client, url: m.Target, username: cfg.Username, password: cfg.Password}, nil
+	case "file":
+		return localFileSource{path: u.Path}, nil
+	case "sftp":
+		return newSFTPFileSource(e.ctx, u, cfg, network)
+	case "s3":
+		return newS3FileSource(u, cfg, network)
+	}
+	return nil, fmt.Errorf("unsupported target scheme %q", u.Scheme)
+}
+
+type httpFileSource struct {
+	client             *http.Client
+	url                string
+	username, password string
+}
+
+func (s *httpFileSource) do(ctx context.Context, method string) (*http.Response, fileStat, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.url, nil)
+	if err != nil {
+		return nil, fileStat{}, fmt.Errorf("request creation failed: %v", err)
+	}
+	if s.username != "" || s.password != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fileStat{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fileStat{}, fmt.Errorf("HTTP Error: %s", resp.Status)
+	}
+	st := fileStat{Size: resp.ContentLength, ETag: resp.Header.Get("ETag")}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		st.ModTime = t
+	}
+	return resp, st, nil
+}
+
+func (s *httpFileSource) Stat(ctx context.Context) (fileStat, error) {
+	resp, st, err := s.do(ctx, http.MethodHead)
+	if err != nil {
+		return st, err
+	}
+	resp.Body.Close()
+	return st, nil
+}
+
+func (s *httpFileSource) Open(ctx context.Context) (io.ReadCloser, fileStat, error) {
+	resp, st, err := s.do(ctx, http.MethodGet)
+	if err != nil {
+		return nil, st, err
+	}
+	return resp.Body, st, nil
+}
+
+func (s *httpFileSource) Close() error { return nil }
+
+type localFileSource struct {
+	path string
+}
+
+func (s localFileSource) Stat(context.Context) (fileStat, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fileStat{}, err
+	}
+	return fileStat{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s localFileSource) Open(ctx context.Context) (io.ReadCloser, fileStat, error) {
+	st, err := s.Stat(ctx)
+	if err != nil {
+		return nil, st, err
+	}
+	f, err := os.Open(s.path)
+	return f, st, err
+}
+
+func (s localFileSource) Close() error { return nil }
+
+type sftpFileSource struct {
+	ssh  *ssh.Client
+	sftp *sftp.Client
+	path string
+}
+
+// newSFTPFileSource connects and logs in within the check timeout, giving up
+// early when ctx (the engine) is cancelled.
+func newSFTPFileSource(ctx context.Context, u *url.URL, cfg fileUpdateConfig, network networkConfig) (*sftpFileSource, error) {
+	login := sshConfig{
+		Username:           cfg.Username,
+		Password:           cfg.Password,
+		PrivateKey:         cfg.PrivateKey,
+		Passphrase:         cfg.Passphrase,
+		HostKeyFingerprint: cfg.HostKeyFingerprint,
+	}
+	if u.User != nil && login.Username == "" {
+		login.Username = u.User.Username()
+	}
+	auth, err := login.authMethods()
+	if err != nil {
+		return nil, err
+	}
+	dial, err := network.dialer(sshDefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, sshDefaultTimeout)
+	defer cancel()
+	addr := withDefaultPort(u.Host, sshDefaultPort)
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("SFTP connection failed: %v", err)
+	}
+	// The SSH handshake does not take a context
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	conn.SetDeadline(time.Now().Add(sshDefaultTimeout))
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            login.Username,
+		Auth:            auth,
+		HostKeyCallback: login.hostKeyCallback(),
+		Timeout:         sshDefaultTimeout,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SFTP login failed: %v", err)
+	}
+	conn.SetDeadline(time.Time{})
+	client := ssh.NewClient(sshConn, chans, reqs)
+	sc, err := sftp.NewClient(client)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("SFTP session failed: %v", err)
+	}
+	return &sftpFileSource{ssh: client, sftp: sc, path: u.Path}, nil
+}
+
+func (s *sftpFileSource) Stat(context.Context) (fileStat, error) {
+	info, err := s.sftp.Stat(s.path)
+	if err != nil {
+		return fileStat{}, err
+	}
+	return fileStat{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s *sftpFileSource) Open(ctx context.Context) (io.ReadCloser, fileStat, error) {
+	st, err := s.Stat(ctx)
+	if err != nil {
+		return nil, st, err
+	}
+	f, err := s.sftp.Open(s.path)
+	return f, st, err
+}
+
+func (s *sftpFileSource) Close() error {
+	s.sftp.Close()
+	return s.ssh.Close()
+}
+
+type s3FileSource struct {
+	client      *minio.Client
+	bucket, key string
+}
+
+func newS3FileSource(u *url.URL, cfg fileUpdateConfig, network networkConfig) (*s3FileSource, error) {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "s3.amazonaws.com"
+	}
+	opts := &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: !cfg.DisableTLS,
+		Region: cfg.Region,
+	}
+	if !network.isDefault() {
+		t, err := network.transport()
+		if err != nil {
+			return nil, err
+		}
+		opts.Transport = t
+	}
+	client, err := minio.New(endpoint, opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 settings: %v", err)
+	}
+	return &s3FileSource{client: client, bucket: u.Host, key: strings.TrimPrefix(u.Path, "/")}, nil
+}
+
+func (s *s3FileSource) Stat(ctx context.Context) (fileStat, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, s.key, minio.StatObjectOptions{})
+	if err != nil {
+		return fileStat{}, err
+	}
+	return fileStat{Size: info.Size, ModTime: info.LastModified, ETag: info.ETag}, nil
+}
+
+func (s *s3FileSource) Open(ctx context.Context) (io.ReadCloser, fileStat, error) {
+	st, err := s.Stat(ctx)
+	if err != nil {
+		return nil, st, err
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, s.key, minio.GetObjectOptions{})
+	return obj, st, err
+}
+
+func (s *s3FileSource) Close() error { return nil }
+
+// contentScanner counts lines and looks for needles while the content streams
+// through it, so large files are never held in memory.
+type contentScanner struct {
+	needles []string
+	found   []bool
+	tail    []byte
+	size    int64
+	lines   int
+	last    byte
+}
+
+func newContentScanner(needles []string) *contentScanner {
+	return &contentScanner{needles: needles, found: make([]bool, len(needles))}
+}
+
+func (s *contentScanner) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	s.size += int64(len(p))
+	s.lines += bytes.Count(p, []byte{'\n'})
+	s.last = p[len(p)-1]
+
+	if len(s.needles) > 0 {
+		// Keep the end of the previous chunk so matches across writes are found
+		buf := append(s.tail, p...)
+		keep := 0
+		for i, n := range s.needles {
+			if !s.found[i] && bytes.Contains(buf, []byte(n)) {
+				s.found[i] = true
+			}
+			if len(n) > keep {
+				keep = len(n)
+			}
+		}
+		if keep--; len(buf) > keep {
+			buf = buf[len(buf)-keep:]
+		}
+		s.tail = append([]byte(nil), buf...)
+	}
+	return len(p), nil
+}
+
+func (s *contentScanner) lineCount() int {
+	if s.size > 0 && s.last != '\n' {
+		return s.lines + 1
+	}
+	return s.lines
+}
+
+// missing returns the first needle that was not found.
+func (s *contentScanner) missing() (string, bool) {
+	for i, n := range s.needles {
+		if !s.found[i] {
+			return n, true
+		}
+	}
+	return "", false
+}
+
+func (e *Engine) checkFileUpdate(m Monitor) Result {
+	start := time.Now().UTC()
+	down := func(msg string) Result {
+		return Result{MonitorID: m.ID, Status: "down", Latency: int(time.Since(start).Milliseconds()), Message: msg}
+	}
+
+	cfg, err := parseFileUpdateConfig(m)
+	if err != nil {
+		return down(err.Error())
+	}
+
+	// 1. Fetch Last Heartbeat to get history
+	var lastDataStr string
+	e.db.Get(&lastDataStr, "SELECT data FROM heartbeats WHERE monitor_id = ? AND status = 'up' ORDER BY timestamp DESC LIMIT 1", m.ID)
+
+	var lastData struct {
+		CurrentMD5  string `json:"current_md5"`
+		Fingerprint string `json:"fingerprint"`
+		LastChanged string `json:"last_changed"`
+	}
+	if lastDataStr != "" {
+		json.Unmarshal([]byte(lastDataStr), &lastData)
+	}
+
+	// 2. Stat or download the file
+	src, err := e.newFileSource(m, cfg)
+	if err != nil {
+		return down(err.Error())
+	}
+	defer src.Close()
+
+	resultData := make(map[string]interface{})
+	var st fileStat
+	var scanner *contentScanner
+	var currentMD5 string
+	if cfg.needsContent() {
+		body, stat, err := src.Open(e.ctx)
+		if err != nil {
+			return down(fmt.Sprintf("Download failed: %v", err))
+		}
+		hash := md5.New()
+		scanner = newContentScanner(cfg.needles())
+		_, err = io.Copy(io.MultiWriter(hash, scanner), body)
+		body.Close()
+		if err != nil {
+			return down(fmt.Sprintf("Hashing failed: %v", err))
+		}
+		st = stat
+		st.Size = scanner.size
+		currentMD5 = hex.EncodeToString(hash.Sum(nil))
+		resultData["lines"] = scanner.lineCount()
+	} else if st, err = src.Stat(e.ctx); err != nil {
+		return down(fmt.Sprintf("Stat failed: %v", err))
+	}
+	latency := int(time.Since(start).Milliseconds())
+
+	if st.Size >= 0 {
+		resultData["size"] = st.Size
+	}
+	if st.ETag != "" {
+		resultData["etag"] = st.ETag
+	}
+	if !st.ModTime.IsZero() {
+		resultData["last_modified"] = st.ModTime.UTC().Format(time.RFC3339)
+	}
+
+	// 3. Size and content checks
+	fail := func(msg string) Result {
+		return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: msg, Data: marshalData(resultData)}
+	}
+	if cfg.MinSize != nil && st.Size >= 0 && st.Size < *cfg.MinSize {
+		return fail(fmt.Sprintf("File too small: %d bytes, expected at least %d", st.Size, *cfg.MinSize))
+	}
+	if cfg.MaxSize != nil && st.Size > *cfg.MaxSize {
+		return fail(fmt.Sprintf("File too large: %d bytes, expected at most %d", st.Size, *cfg.MaxSize))
+	}
+	if scanner != nil {
+		if cfg.MinLines != nil && scanner.lineCount() < *cfg.MinLines {
+			return fail(fmt.Sprintf("File has %d lines, expected at least %d", scanner.lineCount(), *cfg.MinLines))
+		}
+		if cfg.MaxLines != nil && scanner.lineCount() > *cfg.MaxLines {
+			return fail(fmt.Sprintf("File has %d lines, expected at most %d", scanner.lineCount(), *cfg.MaxLines))
+		}
+		if needle, ok := scanner.missing(); ok {
+			return fail(fmt.Sprintf("File does not contain %q", needle))
+		}
+	}
+
+	// 4. Determine State
+	var current, previous string
+	if cfg.Detection == fileDetectHash {
+		current, previous = currentMD5, lastData.CurrentMD5
+		resultData["current_md5"] = currentMD5
+	} else {
+		switch {
+		case st.ETag != "":
+			current = "etag:" + st.ETag
+		case !st.ModTime.IsZero():
+			current = fmt.Sprintf("mtime:%d:%d", st.ModTime.Unix(), st.Size)
+		default:
+			return fail("Server reports neither ETag nor Last-Modified; use hash detection")
+		}
+		previous = lastData.Fingerprint
+		resultData["fingerprint"] = current
+	}
+
+	now := time.Now().UTC()
+	lastChangedTime := now // Default if new
+	if lastData.LastChanged != "" {
+		if t, err := time.Parse(time.RFC3339, lastData.LastChanged); err == nil {
+			lastChangedTime = t
+		}
+	}
+
+	if current != previous {
+		// File Changed! Update last changed time to NOW
+		lastChangedTime = now
+	}
+	if cfg.Detection == fileDetectMetadata && !st.ModTime.IsZero() {
+		// The source knows when the file changed; trust it over our observation
+		lastChangedTime = st.ModTime.UTC()
+	}
+
+	status := "up"
+	var message string
+	switch {
+	case now.Sub(lastChangedTime) > cfg.expectedInterval():
+		status = "down"
+		message = fmt.Sprintf("File stale! Not updated in %s", now.Sub(lastChangedTime).Round(time.Minute))
+	case previous == "":
+		// No previous record: this check is the baseline
+		message = "New file monitoring started"
+	case current != previous:
+		message = "File updated recently"
+	default:
+		message = fmt.Sprintf("File valid. Last update: %s ago", now.Sub(lastChangedTime).Round(time.Minute))
+	}
+
+	resultData["last_changed"] = lastChangedTime.Format(time.RFC3339)
+
+	return Result{
+		MonitorID: m.ID,
+		Status:    status,
+		Latency:   latency,
+		Message:   message,
+		Data:      marshalData(resultData),
+	}
+}
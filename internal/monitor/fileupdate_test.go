@@ -0,0 +1,155 @@
+package monitor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"aeromonitor/internal/db"
+)
+
+func TestParseFileUpdateConfig(t *testing.T) {
+	tests := []struct {
+		target       string
+		metadata     string
+		detection    string
+		interval     time.Duration
+		needsContent bool
+	}{
+		{"https://example.com/feed.xml", "", fileDetectHash, 15 * time.Minute, true},
+		{"https://example.com/feed.xml", `{"expected_update_interval":60}`, fileDetectHash, time.Hour, true},
+		{"/var/backups/db.sql.gz", `{"detection":"metadata"}`, fileDetectMetadata, 15 * time.Minute, false},
+		{"s3://bucket/key", `{"detection":"metadata","min_size":1}`, fileDetectMetadata, 15 * time.Minute, false},
+		{"s3://bucket/key", `{"detection":"metadata","min_lines":10}`, fileDetectMetadata, 15 * time.Minute, true},
+		{"s3://bucket/key", `{"detection":"metadata","contains":"END"}`, fileDetectMetadata, 15 * time.Minute, true},
+		{"sftp://host/file", `{"host_key_fingerprint":"SHA256:abc"}`, fileDetectHash, 15 * time.Minute, true},
+		{"sftp://host/file", `{"insecure_ignore_host_key":true}`, fileDetectHash, 15 * time.Minute, true},
+		{"/tmp/report.csv", `{"contains_date":"2006-01-02","timezone":"Europe/Berlin"}`, fileDetectHash, 15 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		cfg, err := parseFileUpdateConfig(Monitor{Target: tt.target, Metadata: tt.metadata})
+		if err != nil {
+			t.Errorf("parseFileUpdateConfig(%q, %q): %v", tt.target, tt.metadata, err)
+			continue
+		}
+		if cfg.Detection != tt.detection || cfg.expectedInterval() != tt.interval || cfg.needsContent() != tt.needsContent {
+			t.Errorf("parseFileUpdateConfig(%q, %q) = %q %v content %v, want %q %v content %v", tt.target, tt.metadata,
+				cfg.Detection, cfg.expectedInterval(), cfg.needsContent(), tt.detection, tt.interval, tt.needsContent)
+		}
+	}
+}
+
+func TestParseFileUpdateConfigErrors(t *testing.T) {
+	tests := []struct {
+		target   string
+		metadata string
+	}{
+		{"/tmp/file", `{"detection":"mtime"}`},
+		{"/tmp/file", `{"timezone":"Mars/Olympus"}`},
+		{"sftp://host/file", ""},
+	}
+	for _, tt := range tests {
+		if _, err := parseFileUpdateConfig(Monitor{Target: tt.target, Metadata: tt.metadata}); err == nil {
+			t.Errorf("parseFileUpdateConfig(%q, %q): expected an error", tt.target, tt.metadata)
+		}
+	}
+}
+
+func TestFileUpdateNeedles(t *testing.T) {
+	cfg := fileUpdateConfig{Contains: "TOTAL", ContainsDate: "2006-01-02", Timezone: "UTC"}
+	want := []string{"TOTAL", time.Now().UTC().Format("2006-01-02")}
+	if got := cfg.needles(); !reflect.DeepEqual(got, want) {
+		t.Errorf("needles() = %q, want %q", got, want)
+	}
+	if got := (fileUpdateConfig{}).needles(); len(got) != 0 {
+		t.Errorf("needles() = %q, want none", got)
+	}
+}
+
+func TestContentScanner(t *testing.T) {
+	tests := []struct {
+		chunks  []string
+		needles []string
+		size    int64
+		lines   int
+		missing string
+	}{
+		{nil, nil, 0, 0, ""},
+		{[]string{"a\nb\nc\n"}, nil, 6, 3, ""},
+		{[]string{"a\nb\nc"}, nil, 5, 3, ""},
+		{[]string{"a\n", "b", "\nc"}, nil, 5, 3, ""},
+		{[]string{"header\n", "rows\n", "TOTAL 42\n"}, []string{"TOTAL"}, 21, 3, ""},
+		// A needle split across writes is still found
+		{[]string{"...TO", "T", "AL..."}, []string{"TOTAL"}, 11, 1, ""},
+		{[]string{"header\n", "rows\n"}, []string{"header", "TOTAL"}, 12, 2, "TOTAL"},
+		{[]string{"x"}, []string{"a", "b"}, 1, 1, "a"},
+	}
+	for _, tt := range tests {
+		s := newContentScanner(tt.needles)
+		for _, c := range tt.chunks {
+			s.Write([]byte(c))
+		}
+		missing, _ := s.missing()
+		if s.size != tt.size || s.lineCount() != tt.lines || missing != tt.missing {
+			t.Errorf("scan %q for %q = %d bytes %d lines missing %q, want %d bytes %d lines missing %q",
+				strings.Join(tt.chunks, "|"), tt.needles, s.size, s.lineCount(), missing, tt.size, tt.lines, tt.missing)
+		}
+	}
+}
+
+func TestCheckFileUpdate(t *testing.T) {
+	dir := t.TempDir()
+	d, err := db.InitDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	e := NewEngine(d, nil)
+	defer e.Stop()
+
+	path := filepath.Join(dir, "report.csv")
+	if err := os.WriteFile(path, []byte("id,value\n1,10\n2,20\nTOTAL,30\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stale := filepath.Join(dir, "stale.csv")
+	if err := os.WriteFile(stale, []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target   string
+		metadata string
+		status   string
+		message  string
+		lines    float64
+	}{
+		{path, "", "up", "New file monitoring started", 4},
+		{path, `{"contains":"TOTAL","min_lines":4,"max_size":1024}`, "up", "New file monitoring started", 4},
+		{path, `{"min_size":1024}`, "down", "File too small: 28 bytes, expected at least 1024", 4},
+		{path, `{"max_lines":2}`, "down", "File has 4 lines, expected at most 2", 4},
+		{path, `{"contains":"GRAND TOTAL"}`, "down", `File does not contain "GRAND TOTAL"`, 4},
+		{path, `{"detection":"metadata"}`, "up", "New file monitoring started", 0},
+		{stale, `{"detection":"metadata","expected_update_interval":60}`, "down", "File stale! Not updated in 2h0m0s", 0},
+		{filepath.Join(dir, "missing.csv"), `{"detection":"metadata"}`, "down", "Stat failed", 0},
+	}
+	for _, tt := range tests {
+		r := e.checkFileUpdate(Monitor{ID: "m1", Target: tt.target, Metadata: tt.metadata})
+		if r.Status != tt.status || !strings.HasPrefix(r.Message, tt.message) {
+			t.Errorf("checkFileUpdate(%s, %q) = %s %q, want %s %q", filepath.Base(tt.target), tt.metadata,
+				r.Status, r.Message, tt.status, tt.message)
+		}
+		var data map[string]interface{}
+		json.Unmarshal([]byte(r.Data), &data)
+		if lines, _ := data["lines"].(float64); lines != tt.lines {
+			t.Errorf("checkFileUpdate(%s, %q) lines = %v, want %v", filepath.Base(tt.target), tt.metadata, lines, tt.lines)
+		}
+	}
+}
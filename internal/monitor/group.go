@@ -0,0 +1,246 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Group rules
+const (
+	groupRuleAll     = "all"      // Every child is up (default)
+	groupRuleAtLeast = "at_least" // At least min_up children are up
+	groupRuleQuorum  = "quorum"   // Weighted majority, or at least min_weight
+)
+
+// groupConfig is the metadata of group monitors. Children are monitor IDs;
+// paused or deleted children, and children without a result yet, are left out
+// of the calculation.
+//
+//	{"monitors": ["node-1", "node-2", "node-3"], "rule": "at_least", "min_up": 2}
+//	{"monitors": ["eu", "us", "ap"], "rule": "quorum", "weights": {"eu": 2}}
+type groupConfig struct {
+	Monitors  []string           `json:"monitors"`
+	Rule      string             `json:"rule"`
+	MinUp     int                `json:"min_up"`
+	Weights   map[string]float64 `json:"weights"` // Default weight is 1
+	MinWeight float64            `json:"min_weight"`
+}
+
+func parseGroupConfig(m Monitor) (groupConfig, error) {
+	var cfg groupConfig
+	if m.Metadata != "" {
+		if err := json.Unmarshal([]byte(m.Metadata), &cfg); err != nil {
+			return cfg, fmt.Errorf("invalid group metadata: %v", err)
+		}
+	}
+	if len(cfg.Monitors) == 0 {
+		return cfg, errors.New("group monitor requires child monitors")
+	}
+	for _, id := range cfg.Monitors {
+		if id == m.ID {
+			return cfg, errors.New("group monitor cannot contain itself")
+		}
+	}
+	switch cfg.Rule {
+	case "":
+		cfg.Rule = groupRuleAll
+	case groupRuleAll, groupRuleQuorum:
+	case groupRuleAtLeast:
+		if cfg.MinUp < 1 || cfg.MinUp > len(cfg.Monitors) {
+			return cfg, fmt.Errorf("min_up must be between 1 and %d", len(cfg.Monitors))
+		}
+	default:
+		return cfg, fmt.Errorf("invalid group rule %q", cfg.Rule)
+	}
+	for id, w := range cfg.Weights {
+		if w < 0 {
+			return cfg, fmt.Errorf("negative weight for %s", id)
+		}
+	}
+	return cfg, nil
+}
+
+func (cfg groupConfig) weight(id string) float64 {
+	if w, ok := cfg.Weights[id]; ok {
+		return w
+	}
+	return 1
+}
+
+func (cfg groupConfig) contains(id string) bool {
+	for _, child := range cfg.Monitors {
+		if child == id {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGroupCycle rejects a group configuration through which the group would
+// contain itself via other groups, like A -> B -> A. The stored groups are
+// read with m's new configuration in place of its current one.
+func (e *Engine) checkGroupCycle(m Monitor, cfg groupConfig) error {
+	var groups []Monitor
+	if err := e.db.Select(&groups, "SELECT * FROM monitors WHERE type = ?", TypeGroup); err != nil {
+		return fmt.Errorf("loading group monitors failed: %v", err)
+	}
+	children := map[string][]string{m.ID: cfg.Monitors}
+	for _, g := range groups {
+		if g.ID == m.ID {
+			continue
+		}
+		if c, err := parseGroupConfig(g); err == nil {
+			children[g.ID] = c.Monitors
+		}
+	}
+
+	visited := make(map[string]bool)
+	var walk func(path []string) []string
+	walk = func(path []string) []string {
+		for _, child := range children[path[len(path)-1]] {
+			if child == m.ID {
+				return append(path, child)
+			}
+			if visited[child] {
+				continue
+			}
+			visited[child] = true
+			if cycle := walk(append(path, child)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	if cycle := walk([]string{m.ID}); cycle != nil {
+		return fmt.Errorf("group monitor would contain itself: %s", strings.Join(cycle, " -> "))
+	}
+	return nil
+}
+
+// checkGroup derives the group status from the current status of its children
+// in e.status. Degraded children count as up. It reports false while active
+// children exist but none of them has a result yet, as after a restart.
+func (e *Engine) checkGroup(m Monitor) (Result, bool) {
+	cfg, err := parseGroupConfig(m)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}, true
+	}
+
+	var children []Monitor
+	if err := e.db.Select(&children, "SELECT * FROM monitors"); err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: fmt.Sprintf("Loading child monitors failed: %v", err)}, true
+	}
+	byID := make(map[string]Monitor, len(children))
+	for _, c := range children {
+		byID[c.ID] = c
+	}
+
+	statuses := make(map[string]string)
+	var down, unknown []string
+	total, upCount := 0, 0
+	var totalWeight, upWeight float64
+
+	e.mu.RLock()
+	for _, id := range cfg.Monitors {
+		child, ok := byID[id]
+		if !ok || child.Paused {
+			continue
+		}
+		status := e.status[id]
+		if status == "" {
+			statuses[child.Name] = "unknown"
+			unknown = append(unknown, child.Name)
+			continue
+		}
+		statuses[child.Name] = status
+
+		total++
+		totalWeight += cfg.weight(id)
+		if status == "up" || status == "degraded" {
+			upCount++
+			upWeight += cfg.weight(id)
+		} else {
+			down = append(down, child.Name)
+		}
+	}
+	e.mu.RUnlock()
+
+	data := map[string]interface{}{
+		"children": statuses,
+		"up":       upCount,
+		"total":    total,
+	}
+	if cfg.Rule == groupRuleQuorum {
+		data["up_weight"] = upWeight
+		data["total_weight"] = totalWeight
+	}
+
+	if total == 0 {
+		if len(unknown) > 0 {
+			return Result{}, false
+		}
+		return Result{MonitorID: m.ID, Status: "down", Message: "No active child monitors", Data: marshalData(data)}, true
+	}
+
+	var healthy bool
+	var requirement string
+	switch cfg.Rule {
+	case groupRuleAll:
+		healthy = upCount == total
+		requirement = "all"
+	case groupRuleAtLeast:
+		healthy = upCount >= cfg.MinUp
+		requirement = fmt.Sprintf("at least %d", cfg.MinUp)
+	case groupRuleQuorum:
+		if cfg.MinWeight > 0 {
+			healthy = upWeight >= cfg.MinWeight
+			requirement = fmt.Sprintf("weight %g", cfg.MinWeight)
+		} else {
+			healthy = upWeight > totalWeight/2
+			requirement = "a weighted majority"
+		}
+	}
+
+	message := fmt.Sprintf("%d/%d up", upCount, total)
+	if len(down) > 0 {
+		sort.Strings(down)
+		message += fmt.Sprintf(", not up: %s", strings.Join(down, ", "))
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		message += fmt.Sprintf(", no result yet: %s", strings.Join(unknown, ", "))
+	}
+	if !healthy {
+		return Result{MonitorID: m.ID, Status: "down", Message: fmt.Sprintf("%s (requires %s)", message, requirement), Data: marshalData(data)}, true
+	}
+	return Result{MonitorID: m.ID, Status: "up", Message: message, Data: marshalData(data)}, true
+}
+
+// updateParentGroups re-evaluates the groups containing a monitor whose status
+// just changed, so groups react without waiting for their interval.
+func (e *Engine) updateParentGroups(childID string) {
+	var groups []Monitor
+	if err := e.db.Select(&groups, "SELECT * FROM monitors WHERE type = ?", TypeGroup); err != nil {
+		log.Printf("Error fetching group monitors: %v", err)
+		return
+	}
+	for _, g := range groups {
+		if g.Paused {
+			continue
+		}
+		cfg, err := parseGroupConfig(g)
+		if err != nil || !cfg.contains(childID) {
+			continue
+		}
+		result, reported := e.checkGroup(g)
+		if !reported {
+			continue
+		}
+		e.applyRules(g, &result)
+		e.saveResult(result)
+	}
+}
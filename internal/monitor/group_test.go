@@ -0,0 +1,155 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"aeromonitor/internal/db"
+)
+
+func TestParseGroupConfig(t *testing.T) {
+	cfg, err := parseGroupConfig(Monitor{ID: "g", Metadata: `{"monitors": ["a", "b"]}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Rule != groupRuleAll || cfg.weight("a") != 1 || !cfg.contains("b") || cfg.contains("g") {
+		t.Errorf("parseGroupConfig = %+v", cfg)
+	}
+	for _, metadata := range []string{
+		"",
+		`{"monitors": []}`,
+		`{"monitors": ["a", "g"]}`,
+		`{"monitors": ["a"], "rule": "any"}`,
+		`{"monitors": ["a", "b"], "rule": "at_least"}`,
+		`{"monitors": ["a", "b"], "rule": "at_least", "min_up": 3}`,
+		`{"monitors": ["a"], "weights": {"a": -1}}`,
+		`{"monitors": "a"}`,
+	} {
+		if _, err := parseGroupConfig(Monitor{ID: "g", Metadata: metadata}); err == nil {
+			t.Errorf("parseGroupConfig(%q): expected an error", metadata)
+		}
+	}
+}
+
+// newGroupTestEngine returns an engine whose database holds the given
+// monitors, as id -> type:metadata.
+func newGroupTestEngine(t *testing.T, monitors map[string]string) *Engine {
+	t.Helper()
+	d, err := db.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	e := NewEngine(d, nil)
+	t.Cleanup(e.Stop)
+	for id, def := range monitors {
+		typ, metadata, _ := strings.Cut(def, ":")
+		paused := strings.HasSuffix(id, "-paused")
+		if _, err := d.Exec(`INSERT INTO monitors (id, owner_id, name, type, target, interval, notification_channels, metadata, monitor_group, paused)
+			VALUES (?, 'o', ?, ?, '', 60, '[]', ?, '', ?)`, id, strings.ToUpper(id), typ, metadata, paused); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return e
+}
+
+func TestCheckGroup(t *testing.T) {
+	e := newGroupTestEngine(t, map[string]string{
+		"eu": "http:{}", "us": "http:{}", "ap": "http:{}", "sa-paused": "http:{}",
+	})
+
+	tests := []struct {
+		metadata string
+		statuses map[string]string
+		reported bool
+		status   string
+		message  string
+	}{
+		{`{"monitors": ["eu", "us", "ap"]}`, map[string]string{"eu": "up", "us": "degraded", "ap": "up"},
+			true, "up", "3/3 up"},
+		{`{"monitors": ["eu", "us", "ap"]}`, map[string]string{"eu": "up", "us": "down", "ap": "late"},
+			true, "down", "1/3 up, not up: AP, US (requires all)"},
+		// Children without a result are left out of the totals
+		{`{"monitors": ["eu", "us", "ap"]}`, map[string]string{"eu": "up"},
+			true, "up", "1/1 up, no result yet: AP, US"},
+		{`{"monitors": ["eu", "us"]}`, map[string]string{},
+			false, "", ""},
+		// Paused and deleted children are ignored
+		{`{"monitors": ["eu", "sa-paused", "gone"]}`, map[string]string{"eu": "up", "sa-paused": "down"},
+			true, "up", "1/1 up"},
+		{`{"monitors": ["sa-paused", "gone"]}`, map[string]string{},
+			true, "down", "No active child monitors"},
+		{`{"monitors": ["eu", "us", "ap"], "rule": "at_least", "min_up": 2}`, map[string]string{"eu": "up", "us": "up", "ap": "down"},
+			true, "up", "2/3 up, not up: AP"},
+		{`{"monitors": ["eu", "us", "ap"], "rule": "at_least", "min_up": 2}`, map[string]string{"eu": "up", "us": "down", "ap": "down"},
+			true, "down", "1/3 up, not up: AP, US (requires at least 2)"},
+		{`{"monitors": ["eu", "us", "ap"], "rule": "quorum"}`, map[string]string{"eu": "up", "us": "up", "ap": "down"},
+			true, "up", "2/3 up, not up: AP"},
+		// A tie is not a majority
+		{`{"monitors": ["eu", "us"], "rule": "quorum"}`, map[string]string{"eu": "up", "us": "down"},
+			true, "down", "1/2 up, not up: US (requires a weighted majority)"},
+		{`{"monitors": ["eu", "us", "ap"], "rule": "quorum", "weights": {"eu": 3}}`, map[string]string{"eu": "up", "us": "down", "ap": "down"},
+			true, "up", "1/3 up, not up: AP, US"},
+		{`{"monitors": ["eu", "us", "ap"], "rule": "quorum", "min_weight": 2}`, map[string]string{"eu": "up", "us": "down", "ap": "down"},
+			true, "down", "1/3 up, not up: AP, US (requires weight 2)"},
+		{`{"monitors": []}`, map[string]string{}, true, "down", "group monitor requires child monitors"},
+	}
+	for _, tt := range tests {
+		e.mu.Lock()
+		e.status = make(map[string]string)
+		for id, s := range tt.statuses {
+			e.status[id] = s
+		}
+		e.mu.Unlock()
+
+		r, reported := e.checkGroup(Monitor{ID: "g", Metadata: tt.metadata})
+		if reported != tt.reported || r.Status != tt.status || r.Message != tt.message {
+			t.Errorf("%s with %v: %v %s %q, want %v %s %q", tt.metadata, tt.statuses,
+				reported, r.Status, r.Message, tt.reported, tt.status, tt.message)
+		}
+	}
+}
+
+func TestCheckGroupWeights(t *testing.T) {
+	e := newGroupTestEngine(t, map[string]string{"eu": "http:{}", "us": "http:{}"})
+	e.status["eu"], e.status["us"] = "up", "down"
+	r, _ := e.checkGroup(Monitor{ID: "g", Metadata: `{"monitors": ["eu", "us"], "rule": "quorum", "weights": {"eu": 0.5, "us": 2}}`})
+	var data map[string]interface{}
+	json.Unmarshal([]byte(r.Data), &data)
+	if data["up_weight"] != 0.5 || data["total_weight"] != 2.5 || data["up"] != 1.0 || data["total"] != 2.0 {
+		t.Errorf("data = %s", r.Data)
+	}
+}
+
+func TestCheckGroupCycle(t *testing.T) {
+	group := func(children ...string) string {
+		return fmt.Sprintf(`group:{"monitors": ["%s"]}`, strings.Join(children, `", "`))
+	}
+	e := newGroupTestEngine(t, map[string]string{
+		"a": group("b"), "b": group("c", "web"), "c": group("db"), "web": "http:{}", "db": "http:{}",
+	})
+
+	tests := []struct {
+		id       string
+		children []string
+		cycle    string
+	}{
+		{"c", []string{"db", "a"}, "c -> a -> b -> c"},
+		{"c", []string{"db"}, ""},
+		{"b", []string{"a"}, "b -> a -> b"},
+		{"new", []string{"a", "web"}, ""},
+		{"d", []string{"a", "c"}, ""},
+	}
+	for _, tt := range tests {
+		err := e.checkGroupCycle(Monitor{ID: tt.id, Type: TypeGroup}, groupConfig{Monitors: tt.children})
+		switch {
+		case tt.cycle == "" && err != nil:
+			t.Errorf("%s -> %v: %v", tt.id, tt.children, err)
+		case tt.cycle != "" && (err == nil || !strings.HasSuffix(err.Error(), tt.cycle)):
+			t.Errorf("%s -> %v: %v, want cycle %s", tt.id, tt.children, err, tt.cycle)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	grpcmetadata "google.golang.org/grpc/metadata"
+)
+
+const grpcCheckTimeout = 10 * time.Second
+
+// gRPC connection modes
+const (
+	grpcModeInsecure = "insecure" // Plaintext (default)
+	grpcModeTLS      = "tls"
+	grpcModeMTLS     = "mtls"
+)
+
+// grpcConfig is the gRPC-specific part of the monitor metadata. The target is
+// host:port.
+type grpcConfig struct {
+	Service            string            `json:"service"` // Empty checks overall server health
+	Mode               string            `json:"tls_mode"`
+	ServerName         string            `json:"server_name"`
+	InsecureSkipVerify bool              `json:"insecure_skip_verify"`
+	CACert             string            `json:"ca_cert"`     // PEM
+	ClientCert         string            `json:"client_cert"` // PEM, mtls only
+	ClientKey          string            `json:"client_key"`  // PEM, mtls only
+	Headers            map[string]string `json:"headers"`
+}
+
+func parseGRPCConfig(metadata string) (grpcConfig, error) {
+	var cfg grpcConfig
+	if metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &cfg); err != nil {
+			return cfg, fmt.Errorf("invalid grpc metadata: %v", err)
+		}
+	}
+	if _, err := cfg.transportCredentials(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+func (cfg grpcConfig) transportCredentials() (credentials.TransportCredentials, error) {
+	switch cfg.Mode {
+	case "", grpcModeInsecure:
+		return insecure.NewCredentials(), nil
+	case grpcModeTLS, grpcModeMTLS:
+	default:
+		return nil, fmt.Errorf("invalid tls_mode %q", cfg.Mode)
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(cfg.CACert)) {
+			return nil, errors.New("invalid ca_cert")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.Mode == grpcModeMTLS {
+		cert, err := tls.X509KeyPair([]byte(cfg.ClientCert), []byte(cfg.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// checkGRPC calls grpc.health.v1.Health/Check; anything but SERVING is down.
+func (e *Engine) checkGRPC(m Monitor) Result {
+	cfg, err := parseGRPCConfig(m.Metadata)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}
+	}
+
+	creds, err := cfg.transportCredentials()
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}
+	}
+
+	conn, err := grpc.NewClient(m.Target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: fmt.Sprintf("Invalid gRPC target: %v", err)}
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(e.ctx, grpcCheckTimeout)
+	defer cancel()
+	for k, v := range cfg.Headers {
+		ctx = grpcmetadata.AppendToOutgoingContext(ctx, k, v)
+	}
+
+	start := time.Now()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: cfg.Service})
+	latency := int(time.Since(start).Milliseconds())
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: fmt.Sprintf("Health check failed: %v", err)}
+	}
+
+	status := resp.GetStatus()
+	data := marshalData(map[string]interface{}{"serving_status": status.String()})
+	if status != healthpb.HealthCheckResponse_SERVING {
+		return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: fmt.Sprintf("Service is %s", status), Data: data}
+	}
+	return Result{MonitorID: m.ID, Status: "up", Latency: latency, Message: "SERVING", Data: data}
+}
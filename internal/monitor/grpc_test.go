@@ -0,0 +1,115 @@
+package monitor
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestGRPCTransportCredentials(t *testing.T) {
+	tests := []struct {
+		cfg      grpcConfig
+		protocol string
+	}{
+		{grpcConfig{}, "insecure"},
+		{grpcConfig{Mode: grpcModeInsecure}, "insecure"},
+		{grpcConfig{Mode: grpcModeTLS, ServerName: "api.example.com"}, "tls"},
+	}
+	for _, tt := range tests {
+		creds, err := tt.cfg.transportCredentials()
+		if err != nil {
+			t.Errorf("transportCredentials(%q): %v", tt.cfg.Mode, err)
+			continue
+		}
+		if got := creds.Info().SecurityProtocol; got != tt.protocol {
+			t.Errorf("transportCredentials(%q) = %q, want %q", tt.cfg.Mode, got, tt.protocol)
+		}
+	}
+
+	for _, cfg := range []grpcConfig{
+		{Mode: "ssl"},
+		{Mode: grpcModeTLS, CACert: "not a certificate"},
+		{Mode: grpcModeMTLS},
+		{Mode: grpcModeMTLS, ClientCert: "not a certificate", ClientKey: "not a key"},
+	} {
+		if _, err := cfg.transportCredentials(); err == nil {
+			t.Errorf("transportCredentials(%+v): expected an error", cfg)
+		}
+	}
+}
+
+func TestParseGRPCConfig(t *testing.T) {
+	cfg, err := parseGRPCConfig(`{"service":"api","tls_mode":"tls","headers":{"x-token":"abc"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Service != "api" || cfg.Mode != grpcModeTLS || cfg.Headers["x-token"] != "abc" {
+		t.Errorf("parseGRPCConfig = %+v", cfg)
+	}
+	for _, metadata := range []string{"", "{}"} {
+		if _, err := parseGRPCConfig(metadata); err != nil {
+			t.Errorf("parseGRPCConfig(%q): %v", metadata, err)
+		}
+	}
+	for _, metadata := range []string{
+		`{"service":`,
+		`{"headers":["x-token"]}`,
+		`{"tls_mode":"ssl"}`,
+		`{"tls_mode":"mtls"}`,
+	} {
+		if _, err := parseGRPCConfig(metadata); err == nil {
+			t.Errorf("parseGRPCConfig(%q): expected an error", metadata)
+		}
+	}
+}
+
+func TestCheckGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := health.NewServer()
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("api", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("worker", healthpb.HealthCheckResponse_NOT_SERVING)
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	e := NewEngine(nil, nil)
+	defer e.Stop()
+
+	tests := []struct {
+		metadata string
+		status   string
+		message  string
+		serving  string
+	}{
+		{"", "up", "SERVING", "SERVING"},
+		{`{"service":"api"}`, "up", "SERVING", "SERVING"},
+		{`{"service":"worker"}`, "down", "Service is NOT_SERVING", "NOT_SERVING"},
+		{`{"service":"missing"}`, "down", "Health check failed", ""},
+		{`{"tls_mode":"ssl"}`, "down", `invalid tls_mode "ssl"`, ""},
+	}
+	for _, tt := range tests {
+		r := e.checkGRPC(Monitor{ID: "m1", Target: lis.Addr().String(), Metadata: tt.metadata})
+		if r.Status != tt.status || !strings.HasPrefix(r.Message, tt.message) {
+			t.Errorf("checkGRPC(%q) = %s %q, want %s %q", tt.metadata, r.Status, r.Message, tt.status, tt.message)
+		}
+		var data struct {
+			ServingStatus string `json:"serving_status"`
+		}
+		if r.Data != "" {
+			json.Unmarshal([]byte(r.Data), &data)
+		}
+		if data.ServingStatus != tt.serving {
+			t.Errorf("checkGRPC(%q) serving_status = %q, want %q", tt.metadata, data.ServingStatus, tt.serving)
+		}
+	}
+}
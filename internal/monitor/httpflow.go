@@ -0,0 +1,379 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxFlowResponse = 1 << 20
+
+// httpFlowConfig is the metadata of http_flow monitors: an ordered list of
+// requests sharing variables and cookies. Relative step URLs are resolved
+// against the monitor target.
+//
+//	{"variables": {"user": "probe"},
+//	 "steps": [
+//	   {"name": "login", "method": "POST", "url": "/api/login", "body": "{\"user\": \"{{user}}\"}",
+//	    "headers": {"Content-Type": "application/json"}, "extract": {"token": "$.token"}},
+//	   {"name": "cart", "url": "/api/cart", "headers": {"Authorization": "Bearer {{token}}"},
+//	    "expected_json": {"$.items[0].sku": "A-1"}}]}
+type httpFlowConfig struct {
+	Variables map[string]string `json:"variables"`
+	Steps     []flowStep        `json:"steps"`
+}
+
+type flowStep struct {
+	Name    string            `json:"name"`
+	Method  string            `json:"method"` // Default GET
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+
+	// ExpectedStatus defaults to any 2xx/3xx, like http monitors
+	ExpectedStatus []int             `json:"expected_status"`
+	ExpectRegex    string            `json:"expect_regex"`  // Matched against the body
+	ExpectedJSON   map[string]string `json:"expected_json"` // JSONPath -> expected value
+
+	// Extract stores values for later steps: variable -> JSONPath, or
+	// "header:<Name>" for a response header
+	Extract map[string]string `json:"extract"`
+}
+
+var flowVariable = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*\}\}`)
+
+func parseHTTPFlowConfig(metadata string) (httpFlowConfig, error) {
+	var cfg httpFlowConfig
+	if err := json.Unmarshal([]byte(metadata), &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid http_flow metadata: %v", err)
+	}
+	if len(cfg.Steps) == 0 {
+		return cfg, errors.New("http_flow monitor requires at least one step")
+	}
+	seen := make(map[string]bool)
+	for i := range cfg.Steps {
+		s := &cfg.Steps[i]
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("step%d", i+1)
+		}
+		if seen[s.Name] {
+			return cfg, fmt.Errorf("duplicate step name %q", s.Name)
+		}
+		seen[s.Name] = true
+		if s.Method == "" {
+			s.Method = http.MethodGet
+		}
+		if s.ExpectRegex != "" {
+			if _, err := regexp.Compile(s.ExpectRegex); err != nil {
+				return cfg, fmt.Errorf("step %s: invalid expect_regex: %v", s.Name, err)
+			}
+		}
+		for path := range s.ExpectedJSON {
+			if _, err := parseJSONPath(path); err != nil {
+				return cfg, fmt.Errorf("step %s: %v", s.Name, err)
+			}
+		}
+		for name, path := range s.Extract {
+			if strings.HasPrefix(path, "header:") {
+				continue
+			}
+			if _, err := parseJSONPath(path); err != nil {
+				return cfg, fmt.Errorf("step %s: extract %s: %v", s.Name, name, err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// stepResult is recorded per step, in flow order, in the heartbeat data.
+type stepResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`  // "up" or "down"
+	Latency    int64  `json:"latency"` // in ms
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (e *Engine) checkHTTPFlow(m Monitor) Result {
+	cfg, err := parseHTTPFlowConfig(m.Metadata)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}
+	}
+	base, err := url.Parse(m.Target)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: fmt.Sprintf("Invalid target: %v", err)}
+	}
+
+	shared, err := e.monitorHTTPClient(m)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}
+	}
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{
+		Transport: shared.Transport,
+		Timeout:   shared.Timeout,
+		Jar:       jar,
+	}
+
+	vars := make(map[string]string, len(cfg.Variables))
+	for k, v := range cfg.Variables {
+		vars[k] = v
+	}
+
+	start := time.Now()
+	steps := make([]stepResult, 0, len(cfg.Steps))
+	data := make(map[string]interface{})
+	for _, step := range cfg.Steps {
+		res, err := e.runFlowStep(client, base, step, vars)
+		steps = append(steps, res)
+		data["steps"] = steps
+		if err != nil {
+			data["failed_step"] = step.Name
+			return Result{
+				MonitorID: m.ID,
+				Status:    "down",
+				Latency:   int(time.Since(start).Milliseconds()),
+				Message:   fmt.Sprintf("Step %s failed: %v", step.Name, err),
+				Data:      marshalData(data),
+			}
+		}
+	}
+
+	return Result{
+		MonitorID: m.ID,
+		Status:    "up",
+		Latency:   int(time.Since(start).Milliseconds()),
+		Message:   fmt.Sprintf("All %d steps passed", len(cfg.Steps)),
+		Data:      marshalData(data),
+	}
+}
+
+func (e *Engine) runFlowStep(client *http.Client, base *url.URL, step flowStep, vars map[string]string) (stepResult, error) {
+	res := stepResult{Name: step.Name, Status: "down"}
+	fail := func(err error) (stepResult, error) {
+		res.Error = err.Error()
+		return res, err
+	}
+
+	rawURL, err := expandFlowVariables(step.URL, vars)
+	if err != nil {
+		return fail(err)
+	}
+	ref, err := url.Parse(rawURL)
+	if err != nil {
+		return fail(fmt.Errorf("invalid url: %v", err))
+	}
+	body, err := expandFlowVariables(step.Body, vars)
+	if err != nil {
+		return fail(err)
+	}
+
+	req, err := http.NewRequestWithContext(e.ctx, step.Method, base.ResolveReference(ref).String(), strings.NewReader(body))
+	if err != nil {
+		return fail(err)
+	}
+	for k, v := range step.Headers {
+		if v, err = expandFlowVariables(v, vars); err != nil {
+			return fail(err)
+		}
+		req.Header.Set(k, v)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		res.Latency = time.Since(start).Milliseconds()
+		return fail(err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxFlowResponse))
+	res.Latency = time.Since(start).Milliseconds()
+	res.StatusCode = resp.StatusCode
+	if err != nil {
+		return fail(fmt.Errorf("reading response: %v", err))
+	}
+
+	if !flowStatusAllowed(step.ExpectedStatus, resp.StatusCode) {
+		return fail(fmt.Errorf("unexpected status %s", resp.Status))
+	}
+	if step.ExpectRegex != "" && !regexp.MustCompile(step.ExpectRegex).Match(respBody) {
+		return fail(errors.New("response did not match expect_regex"))
+	}
+
+	var doc interface{}
+	needsJSON := len(step.ExpectedJSON) > 0
+	for _, path := range step.Extract {
+		if !strings.HasPrefix(path, "header:") {
+			needsJSON = true
+		}
+	}
+	if needsJSON {
+		if err := json.Unmarshal(respBody, &doc); err != nil {
+			return fail(fmt.Errorf("response is not JSON: %v", err))
+		}
+	}
+
+	for path, want := range step.ExpectedJSON {
+		got, ok := evalJSONPath(doc, path)
+		if !ok {
+			return fail(fmt.Errorf("%s not found in response", path))
+		}
+		if want, err = expandFlowVariables(want, vars); err != nil {
+			return fail(err)
+		}
+		if jsonValueString(got) != want {
+			return fail(fmt.Errorf("%s is %s, expected %s", path, jsonValueString(got), want))
+		}
+	}
+
+	for name, path := range step.Extract {
+		if header, ok := strings.CutPrefix(path, "header:"); ok {
+			v := resp.Header.Get(strings.TrimSpace(header))
+			if v == "" {
+				return fail(fmt.Errorf("header %s missing, cannot extract %s", header, name))
+			}
+			vars[name] = v
+			continue
+		}
+		v, ok := evalJSONPath(doc, path)
+		if !ok {
+			return fail(fmt.Errorf("%s not found, cannot extract %s", path, name))
+		}
+		vars[name] = jsonValueString(v)
+	}
+
+	res.Status = "up"
+	return res, nil
+}
+
+func flowStatusAllowed(expected []int, code int) bool {
+	if len(expected) == 0 {
+		return code >= 200 && code < 400
+	}
+	for _, c := range expected {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func expandFlowVariables(s string, vars map[string]string) (string, error) {
+	var missing string
+	out := flowVariable.ReplaceAllStringFunc(s, func(match string) string {
+		name := flowVariable.FindStringSubmatch(match)[1]
+		v, ok := vars[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return v
+	})
+	if missing != "" {
+		return "", fmt.Errorf("undefined variable %s", missing)
+	}
+	return out, nil
+}
+
+// jsonValueString renders a decoded JSON value for comparison and variables:
+// strings as-is, numbers without a trailing ".0", objects and arrays as JSON.
+func jsonValueString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// parseJSONPath parses the supported JSONPath subset: $, .key, ['key'] and
+// [index], e.g. $.data.items[0]['sku'].
+func parseJSONPath(path string) ([]interface{}, error) {
+	p := strings.TrimSpace(path)
+	if !strings.HasPrefix(p, "$") {
+		return nil, fmt.Errorf("invalid JSONPath %q: must start with $", path)
+	}
+	p = p[1:]
+
+	var parts []interface{}
+	for p != "" {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[]")
+			if end < 0 {
+				end = len(p)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q", path)
+			}
+			parts = append(parts, p[:end])
+			p = p[end:]
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unclosed [", path)
+			}
+			inner := strings.TrimSpace(p[1:end])
+			p = p[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				parts = append(parts, inner[1:len(inner)-1])
+				continue
+			}
+			idx, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSONPath %q: bad index %q", path, inner)
+			}
+			parts = append(parts, idx)
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q", path)
+		}
+	}
+	return parts, nil
+}
+
+func evalJSONPath(doc interface{}, path string) (interface{}, bool) {
+	parts, err := parseJSONPath(path)
+	if err != nil {
+		return nil, false
+	}
+	current := doc
+	for _, part := range parts {
+		switch key := part.(type) {
+		case string:
+			obj, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if current, ok = obj[key]; !ok {
+				return nil, false
+			}
+		case int:
+			arr, ok := current.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			if key < 0 {
+				key += len(arr)
+			}
+			if key < 0 || key >= len(arr) {
+				return nil, false
+			}
+			current = arr[key]
+		}
+	}
+	return current, true
+}
@@ -0,0 +1,177 @@
+package monitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{"$", nil},
+		{"$.token", []interface{}{"token"}},
+		{" $.data.items[0]['sku'] ", []interface{}{"data", "items", 0, "sku"}},
+		{`$["odd.key"][-1]`, []interface{}{"odd.key", -1}},
+		{"$[ 2 ].a", []interface{}{2, "a"}},
+	}
+	for _, tt := range tests {
+		got, err := parseJSONPath(tt.path)
+		if err != nil {
+			t.Errorf("parseJSONPath(%q): %v", tt.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseJSONPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	for _, path := range []string{"", "token", "$.", "$..a", "$.a[", "$.a[x]", "$a", "$.a]"} {
+		if _, err := parseJSONPath(path); err == nil {
+			t.Errorf("parseJSONPath(%q): expected an error", path)
+		}
+	}
+}
+
+func TestEvalJSONPath(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"token": "abc", "count": 2, "ok": true, "none": null,
+		"data": {"items": [{"sku": "A-1", "qty": 1.5}, {"sku": "B-2"}]}, "odd.key": "x"}`), &doc)
+
+	tests := []struct {
+		path  string
+		found bool
+		want  string
+	}{
+		{"$.token", true, "abc"},
+		{"$.count", true, "2"},
+		{"$.ok", true, "true"},
+		{"$.none", true, "null"},
+		{"$.data.items[0].sku", true, "A-1"},
+		{"$.data.items[0].qty", true, "1.5"},
+		{"$.data.items[-1]['sku']", true, "B-2"},
+		{`$["odd.key"]`, true, "x"},
+		{"$.data.items[1]", true, `{"sku":"B-2"}`},
+		{"$.missing", false, ""},
+		{"$.data.items[2]", false, ""},
+		{"$.data.items[-3]", false, ""},
+		{"$.token.length", false, ""},
+		{"$.data[0]", false, ""},
+		{"$.data.items.sku", false, ""},
+		{"not a path", false, ""},
+	}
+	for _, tt := range tests {
+		v, ok := evalJSONPath(doc, tt.path)
+		if ok != tt.found || (ok && jsonValueString(v) != tt.want) {
+			t.Errorf("evalJSONPath(%q) = %v, %v, want %q, %v", tt.path, v, ok, tt.want, tt.found)
+		}
+	}
+}
+
+func TestExpandFlowVariables(t *testing.T) {
+	vars := map[string]string{"token": "abc", "user.id": "42", "empty": ""}
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"no variables", "no variables"},
+		{"Bearer {{token}}", "Bearer abc"},
+		{"/users/{{ user.id }}/cart?t={{token}}", "/users/42/cart?t=abc"},
+		{"[{{empty}}]", "[]"},
+		{"{{ not closed", "{{ not closed"},
+		{"{{bad name!}}", "{{bad name!}}"},
+	}
+	for _, tt := range tests {
+		got, err := expandFlowVariables(tt.in, vars)
+		if err != nil || got != tt.want {
+			t.Errorf("expandFlowVariables(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := expandFlowVariables("{{token}} {{session}}", vars); err == nil || err.Error() != "undefined variable session" {
+		t.Errorf("undefined variable: %v", err)
+	}
+}
+
+func TestParseHTTPFlowConfig(t *testing.T) {
+	cfg, err := parseHTTPFlowConfig(`{"steps": [{"url": "/a"}, {"name": "b", "method": "POST", "url": "/b"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Steps[0].Name != "step1" || cfg.Steps[0].Method != http.MethodGet || cfg.Steps[1].Name != "b" {
+		t.Errorf("defaults not applied: %+v", cfg.Steps)
+	}
+	for _, metadata := range []string{
+		"",
+		`{"steps": []}`,
+		`{"steps": [{"name": "a"}, {"name": "a"}]}`,
+		`{"steps": [{"url": "/", "expect_regex": "("}]}`,
+		`{"steps": [{"url": "/", "expected_json": {"token": "x"}}]}`,
+		`{"steps": [{"url": "/", "extract": {"token": "$.a["}]}]}`,
+	} {
+		if _, err := parseHTTPFlowConfig(metadata); err == nil {
+			t.Errorf("parseHTTPFlowConfig(%q): expected an error", metadata)
+		}
+	}
+}
+
+func TestCheckHTTPFlow(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+		w.Header().Set("X-Request-Id", "r-7")
+		w.Write([]byte(`{"token": "abc"}`))
+	})
+	mux.HandleFunc("GET /api/cart", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc" || r.Header.Get("X-Trace") != "r-7" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if c, err := r.Cookie("session"); err != nil || c.Value != "s1" {
+			http.Error(w, "no session", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"items": [{"sku": "A-1"}]}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	e := NewEngine(nil, nil)
+	defer e.Stop()
+
+	flow := func(sku string) string {
+		return `{"steps": [
+			{"name": "login", "method": "POST", "url": "/api/login", "extract": {"token": "$.token", "req": "header:X-Request-Id"}},
+			{"name": "cart", "url": "/api/cart", "headers": {"Authorization": "Bearer {{token}}", "X-Trace": "{{req}}"},
+			 "expected_json": {"$.items[0].sku": "` + sku + `"}}]}`
+	}
+	tests := []struct {
+		metadata string
+		status   string
+		message  string
+		steps    []string
+	}{
+		{flow("A-1"), "up", "All 2 steps passed", []string{"login:up", "cart:up"}},
+		{flow("B-2"), "down", "Step cart failed", []string{"login:up", "cart:down"}},
+		{`{"steps": [{"name": "home", "url": "/missing"}, {"name": "never", "url": "/"}]}`, "down", "Step home failed", []string{"home:down"}},
+	}
+	for _, tt := range tests {
+		r := e.checkHTTPFlow(Monitor{ID: "m1", Target: srv.URL, Metadata: tt.metadata})
+		if r.Status != tt.status || len(r.Message) < len(tt.message) || r.Message[:len(tt.message)] != tt.message {
+			t.Errorf("%s: %s %q, want %s %q", tt.steps, r.Status, r.Message, tt.status, tt.message)
+		}
+		var data struct {
+			Steps []stepResult `json:"steps"`
+		}
+		json.Unmarshal([]byte(r.Data), &data)
+		var steps []string
+		for _, s := range data.Steps {
+			steps = append(steps, s.Name+":"+s.Status)
+		}
+		if !reflect.DeepEqual(steps, tt.steps) {
+			t.Errorf("steps = %v, want %v", steps, tt.steps)
+		}
+	}
+}
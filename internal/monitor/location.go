@@ -0,0 +1,289 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// localLocation is the location label of checks run by the server itself.
+const localLocation = "local"
+
+// locationConfig assigns a monitor to probe locations. Without locations the
+// server checks the monitor itself, as before.
+//
+//	{"locations": ["local", "fra", "nyc"], "location_quorum": 2}
+type locationConfig struct {
+	Locations []string `json:"locations"`
+	Quorum    int      `json:"location_quorum"` // Locations that must agree on down, default a majority
+}
+
+func parseLocationConfig(metadata string) (locationConfig, error) {
+	var cfg locationConfig
+	json.Unmarshal([]byte(metadata), &cfg)
+	seen := make(map[string]bool)
+	for _, loc := range cfg.Locations {
+		if strings.TrimSpace(loc) == "" {
+			return cfg, errors.New("empty location name")
+		}
+		if seen[loc] {
+			return cfg, fmt.Errorf("duplicate location %q", loc)
+		}
+		seen[loc] = true
+	}
+	if cfg.Quorum < 0 || (len(cfg.Locations) > 0 && cfg.Quorum > len(cfg.Locations)) {
+		return cfg, fmt.Errorf("location_quorum must be between 1 and %d", len(cfg.Locations))
+	}
+	return cfg, nil
+}
+
+// distributed reports whether results go through the location quorum.
+func (cfg locationConfig) distributed() bool {
+	return len(cfg.Locations) > 0
+}
+
+func (cfg locationConfig) includes(location string) bool {
+	if !cfg.distributed() {
+		return location == localLocation
+	}
+	for _, loc := range cfg.Locations {
+		if loc == location {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfg locationConfig) quorum() int {
+	if cfg.Quorum > 0 {
+		return cfg.Quorum
+	}
+	return len(cfg.Locations)/2 + 1
+}
+
+// locationResult is the latest result of a monitor from one location.
+type locationResult struct {
+	Status    string    `json:"status"`
+	Latency   int       `json:"latency"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// locationStaleAfter is how long a location result counts towards the quorum.
+func locationStaleAfter(m Monitor) time.Duration {
+	interval := m.Interval
+	if interval < 20 {
+		interval = 20
+	}
+	return 3 * time.Duration(interval) * time.Second
+}
+
+func (e *Engine) loadLocationStatus() {
+	var rows []struct {
+		MonitorID string    `db:"monitor_id"`
+		Location  string    `db:"location"`
+		Status    string    `db:"status"`
+		Latency   int       `db:"latency"`
+		Message   string    `db:"message"`
+		Timestamp time.Time `db:"timestamp"`
+	}
+	err := e.db.Select(&rows, `
+		SELECT l1.monitor_id, l1.location, l1.status, l1.latency, COALESCE(l1.message, '') as message, l1.timestamp
+		FROM location_heartbeats l1
+		JOIN (
+			SELECT monitor_id, location, MAX(timestamp) as max_ts
+			FROM location_heartbeats
+			GROUP BY monitor_id, location
+		) l2 ON l1.monitor_id = l2.monitor_id AND l1.location = l2.location AND l1.timestamp = l2.max_ts
+	`)
+	if err != nil {
+		log.Printf("Failed to load location status: %v", err)
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range rows {
+		if e.locationStatus[r.MonitorID] == nil {
+			e.locationStatus[r.MonitorID] = make(map[string]locationResult)
+		}
+		e.locationStatus[r.MonitorID][r.Location] = locationResult{Status: r.Status, Latency: r.Latency, Message: r.Message, Timestamp: r.Timestamp}
+	}
+}
+
+// recordResult stores a check result. Results of distributed monitors are
+// votes of this location; agents forward everything to the server instead.
+func (e *Engine) recordResult(m Monitor, res Result) {
+	if e.forward == nil {
+		if cfg, _ := parseLocationConfig(m.Metadata); cfg.distributed() {
+			e.saveLocationResult(m, e.location, res, time.Now().UTC())
+			return
+		}
+	}
+	e.saveResult(res)
+}
+
+// saveLocationResult stores the result of one location and, on the leader,
+// re-evaluates the monitor status. Followers leave that to the leader, which
+// picks the result up from location_heartbeats.
+func (e *Engine) saveLocationResult(m Monitor, location string, res Result, ts time.Time) {
+	_, err := e.db.Exec(`
+		INSERT INTO location_heartbeats (monitor_id, location, status, latency, message, data, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, m.ID, location, res.Status, res.Latency, res.Message, res.Data, ts)
+	if err != nil {
+		log.Printf("Error saving location heartbeat: %v", err)
+	}
+
+	e.setLocationResult(m.ID, location, locationResult{Status: res.Status, Latency: res.Latency, Message: res.Message, Timestamp: ts})
+	if e.leading() {
+		e.aggregateLocationVotes(m)
+	}
+}
+
+// setLocationResult records the latest result of a location and reports
+// whether it is newer than the one already known.
+func (e *Engine) setLocationResult(monitorID, location string, r locationResult) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.locationStatus[monitorID] == nil {
+		e.locationStatus[monitorID] = make(map[string]locationResult)
+	}
+	if cur, ok := e.locationStatus[monitorID][location]; ok && !r.Timestamp.After(cur.Timestamp) {
+		return false
+	}
+	e.locationStatus[monitorID][location] = r
+	return true
+}
+
+// aggregateLocationVotes derives the monitor status from the recent location
+// results. The monitor is down only when at least location_quorum locations
+// with a recent result report it down.
+func (e *Engine) aggregateLocationVotes(m Monitor) {
+	cfg, _ := parseLocationConfig(m.Metadata)
+	now := time.Now().UTC()
+
+	e.mu.RLock()
+	votes := e.freshLocationResults(m, cfg, now)
+	e.mu.RUnlock()
+	if len(votes) == 0 {
+		// A late result from an unassigned or reconnecting location
+		return
+	}
+
+	agg := aggregateLocations(m.ID, cfg.quorum(), votes)
+
+	// Persist the aggregate once per interval, or right away on a change
+	e.mu.Lock()
+	write := agg.Status != e.status[m.ID] || now.Sub(e.lastAggregate[m.ID]) >= time.Duration(m.Interval)*time.Second
+	if write {
+		e.lastAggregate[m.ID] = now
+	}
+	e.mu.Unlock()
+	if write {
+		e.saveResult(agg)
+	}
+}
+
+// freshLocationResults returns the recent results of the assigned locations.
+// The caller holds e.mu.
+func (e *Engine) freshLocationResults(m Monitor, cfg locationConfig, now time.Time) map[string]locationResult {
+	votes := make(map[string]locationResult)
+	for _, loc := range cfg.Locations {
+		r, ok := e.locationStatus[m.ID][loc]
+		if ok && now.Sub(r.Timestamp) <= locationStaleAfter(m) {
+			votes[loc] = r
+		}
+	}
+	return votes
+}
+
+func aggregateLocations(monitorID string, quorum int, votes map[string]locationResult) Result {
+	locations := make([]string, 0, len(votes))
+	for loc := range votes {
+		locations = append(locations, loc)
+	}
+	sort.Strings(locations)
+
+	statuses := make(map[string]string, len(votes))
+	var down, degraded []string
+	latencySum, upCount := 0, 0
+	for _, loc := range locations {
+		r := votes[loc]
+		statuses[loc] = r.Status
+		switch r.Status {
+		case "up", "degraded":
+			latencySum += r.Latency
+			upCount++
+			if r.Status == "degraded" {
+				degraded = append(degraded, loc)
+			}
+		default:
+			down = append(down, loc)
+		}
+	}
+
+	res := Result{MonitorID: monitorID, Data: marshalData(map[string]interface{}{"locations": statuses})}
+	if upCount > 0 {
+		res.Latency = latencySum / upCount
+	}
+	switch {
+	case len(down) >= quorum:
+		res.Status = "down"
+		res.Message = fmt.Sprintf("Down from %d/%d locations (%s): %s", len(down), len(votes), strings.Join(down, ", "), votes[down[0]].Message)
+	case len(degraded) > 0:
+		res.Status = "degraded"
+		res.Message = fmt.Sprintf("Degraded from %s: %s", strings.Join(degraded, ", "), votes[degraded[0]].Message)
+	case len(down) > 0:
+		res.Status = "up"
+		res.Message = fmt.Sprintf("Up, down only from %s (quorum %d)", strings.Join(down, ", "), quorum)
+	default:
+		res.Status = "up"
+		res.Message = fmt.Sprintf("Up from %d/%d locations", upCount, len(votes))
+	}
+	return res
+}
+
+// checkLocationsReporting marks a distributed monitor down when none of its
+// locations reported recently, so a lost agent does not freeze the status.
+func (e *Engine) checkLocationsReporting(m Monitor, cfg locationConfig) {
+	e.mu.RLock()
+	votes := e.freshLocationResults(m, cfg, time.Now().UTC())
+	status := e.status[m.ID]
+	e.mu.RUnlock()
+	// New monitors stay unknown until the first location reports
+	if len(votes) > 0 || status == "" || status == "down" {
+		return
+	}
+	e.saveResult(Result{
+		MonitorID: m.ID,
+		Status:    "down",
+		Message:   fmt.Sprintf("No recent results from %s", strings.Join(cfg.Locations, ", ")),
+	})
+}
+
+// locationSummary returns the status per assigned location for the dashboard;
+// locations without a recent result are "unknown".
+func (e *Engine) locationSummary(m Monitor) map[string]string {
+	cfg, _ := parseLocationConfig(m.Metadata)
+	if !cfg.distributed() {
+		return nil
+	}
+	now := time.Now().UTC()
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	summary := make(map[string]string, len(cfg.Locations))
+	for _, loc := range cfg.Locations {
+		r, ok := e.locationStatus[m.ID][loc]
+		if !ok || now.Sub(r.Timestamp) > locationStaleAfter(m) {
+			summary[loc] = "unknown"
+			continue
+		}
+		summary[loc] = r.Status
+	}
+	return summary
+}
@@ -9,9 +9,22 @@ type MonitorType string
 const (
 	TypeHTTP       MonitorType = "http"
 	TypeTCP        MonitorType = "tcp"
+	TypeUDP        MonitorType = "udp"
 	TypePing       MonitorType = "ping"
 	TypePush       MonitorType = "push"
 	TypeFileUpdate MonitorType = "file_update"
+	TypeMQTT       MonitorType = "mqtt"
+	TypePostgres   MonitorType = "postgres"
+	TypeMySQL      MonitorType = "mysql"
+	TypeRedis      MonitorType = "redis"
+	TypeGRPC       MonitorType = "grpc"
+	TypeNTRIP      MonitorType = "ntrip"
+	TypeSSH        MonitorType = "ssh"
+	TypeExec       MonitorType = "exec"
+	TypeHTTPFlow   MonitorType = "http_flow"
+	TypeGroup      MonitorType = "group"
+	TypePrometheus MonitorType = "prometheus"
+	TypeWebSocket  MonitorType = "websocket"
 )
 
 type Monitor struct {
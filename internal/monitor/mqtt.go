@@ -0,0 +1,328 @@
+package monitor
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTT check modes
+const (
+	mqttModeConnect   = "connect"   // Connect to the broker
+	mqttModeRoundtrip = "roundtrip" // Publish to a topic and wait for the echo
+	mqttModeSubscribe = "subscribe" // Ingest messages and alert when the topic goes quiet
+)
+
+const mqttTimeout = 10 * time.Second
+
+// errMQTTNotReady is returned while an earlier check is still connecting the
+// monitor's subscription, or when it was pruned during the connect.
+var errMQTTNotReady = errors.New("MQTT subscription is not ready")
+
+// mqttConfig is the MQTT-specific part of the monitor metadata. The broker URL
+// (tcp://, ssl://, ws://, wss://) is the monitor target.
+type mqttConfig struct {
+	Mode               string `json:"mode"`
+	Topic              string `json:"topic"`
+	QoS                byte   `json:"qos"`
+	Username           string `json:"username"`
+	Password           string `json:"password"`
+	ClientID           string `json:"client_id"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+}
+
+func parseMQTTConfig(m Monitor) (mqttConfig, error) {
+	var cfg mqttConfig
+	json.Unmarshal([]byte(m.Metadata), &cfg)
+	if cfg.Mode == "" {
+		cfg.Mode = mqttModeConnect
+	}
+	switch cfg.Mode {
+	case mqttModeConnect:
+	case mqttModeRoundtrip:
+		if cfg.Topic == "" {
+			cfg.Topic = "aeromonitor/" + m.ID + "/ping"
+		}
+	case mqttModeSubscribe:
+		if cfg.Topic == "" {
+			return cfg, errors.New("mqtt subscribe mode requires a topic")
+		}
+	default:
+		return cfg, fmt.Errorf("invalid mqtt mode %q", cfg.Mode)
+	}
+	if cfg.QoS > 2 {
+		return cfg, fmt.Errorf("invalid mqtt qos %d", cfg.QoS)
+	}
+	return cfg, nil
+}
+
+// isMQTTSubscription reports whether the monitor passively ingests a topic
+// instead of running scheduled checks.
+func isMQTTSubscription(m Monitor) bool {
+	cfg, err := parseMQTTConfig(m)
+	return err == nil && cfg.Mode == mqttModeSubscribe
+}
+
+func (cfg mqttConfig) clientOptions(m Monitor, suffix string) *mqtt.ClientOptions {
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = "aeromonitor-" + m.ID
+	}
+	opts := mqtt.NewClientOptions().
+		AddBroker(m.Target).
+		SetClientID(clientID + suffix).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetConnectTimeout(mqttTimeout).
+		SetWriteTimeout(mqttTimeout).
+		SetCleanSession(true)
+	if cfg.InsecureSkipVerify {
+		opts.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	}
+	return opts
+}
+
+func (e *Engine) checkMQTT(m Monitor) Result {
+	cfg, err := parseMQTTConfig(m)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}
+	}
+
+	opts := cfg.clientOptions(m, "-check").SetAutoReconnect(false)
+	client := mqtt.NewClient(opts)
+
+	start := time.Now()
+	token := client.Connect()
+	if !token.WaitTimeout(mqttTimeout) {
+		return Result{MonitorID: m.ID, Status: "down", Latency: int(time.Since(start).Milliseconds()), Message: "MQTT connect timeout"}
+	}
+	if err := token.Error(); err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Latency: int(time.Since(start).Milliseconds()), Message: fmt.Sprintf("MQTT connect failed: %v", err)}
+	}
+	defer client.Disconnect(250)
+	connectMs := time.Since(start).Milliseconds()
+
+	if cfg.Mode != mqttModeRoundtrip {
+		return Result{
+			MonitorID: m.ID,
+			Status:    "up",
+			Latency:   int(connectMs),
+			Message:   "MQTT broker connection successful",
+			Data:      marshalData(map[string]interface{}{"connect_ms": connectMs}),
+		}
+	}
+
+	// Round trip: subscribe to the topic, publish a nonce and wait for it
+	nonce := fmt.Sprintf("aeromonitor-%d", time.Now().UnixNano())
+	received := make(chan time.Time, 1)
+	sub := client.Subscribe(cfg.Topic, cfg.QoS, func(_ mqtt.Client, msg mqtt.Message) {
+		if string(msg.Payload()) == nonce {
+			select {
+			case received <- time.Now():
+			default:
+			}
+		}
+	})
+	if !sub.WaitTimeout(mqttTimeout) || sub.Error() != nil {
+		return Result{MonitorID: m.ID, Status: "down", Latency: int(connectMs), Message: fmt.Sprintf("MQTT subscribe to %s failed: %v", cfg.Topic, sub.Error())}
+	}
+
+	sent := time.Now()
+	pub := client.Publish(cfg.Topic, cfg.QoS, false, nonce)
+	if !pub.WaitTimeout(mqttTimeout) || pub.Error() != nil {
+		return Result{MonitorID: m.ID, Status: "down", Latency: int(connectMs), Message: fmt.Sprintf("MQTT publish to %s failed: %v", cfg.Topic, pub.Error())}
+	}
+
+	select {
+	case at := <-received:
+		rtt := at.Sub(sent).Milliseconds()
+		return Result{
+			MonitorID: m.ID,
+			Status:    "up",
+			Latency:   int(rtt),
+			Message:   "MQTT round trip successful",
+			Data:      marshalData(map[string]interface{}{"connect_ms": connectMs, "roundtrip_ms": rtt}),
+		}
+	case <-time.After(mqttTimeout):
+		return Result{MonitorID: m.ID, Status: "down", Latency: int(connectMs), Message: fmt.Sprintf("No echo received on %s within %s", cfg.Topic, mqttTimeout)}
+	}
+}
+
+// mqttSubscription is a long-lived subscription feeding messages of one topic
+// into a monitor as push heartbeats.
+type mqttSubscription struct {
+	client      mqtt.Client
+	fingerprint string // target + metadata, to detect configuration changes
+	lastMessage time.Time
+	started     time.Time
+	mu          sync.Mutex
+}
+
+func (s *mqttSubscription) lastSeen() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastMessage.IsZero() {
+		return s.started
+	}
+	return s.lastMessage
+}
+
+// checkMQTTSubscription keeps the monitor's subscription alive and marks it down
+// when no message arrived within the interval, like a push timeout.
+func (e *Engine) checkMQTTSubscription(m Monitor) {
+	sub, err := e.ensureMQTTSubscription(m)
+	if errors.Is(err, errMQTTNotReady) {
+		return
+	}
+	if err != nil {
+		e.mu.RLock()
+		currentStatus := e.status[m.ID]
+		e.mu.RUnlock()
+		if currentStatus != "down" {
+			e.saveResult(Result{MonitorID: m.ID, Status: "down", Message: err.Error()})
+		}
+		return
+	}
+
+	since := time.Since(sub.lastSeen())
+	if since <= time.Duration(m.Interval)*time.Second+defaultPushGrace {
+		return
+	}
+
+	e.mu.RLock()
+	currentStatus := e.status[m.ID]
+	e.mu.RUnlock()
+	if currentStatus != "down" {
+		cfg, _ := parseMQTTConfig(m)
+		e.saveResult(Result{
+			MonitorID: m.ID,
+			Status:    "down",
+			Message:   fmt.Sprintf("No message on %s for %s", cfg.Topic, since.Round(time.Second)),
+		})
+	}
+}
+
+// ensureMQTTSubscription returns the monitor's subscription, connecting it
+// first if needed. The broker is dialed without holding mqttMu, so a slow
+// broker only delays its own monitor.
+func (e *Engine) ensureMQTTSubscription(m Monitor) (*mqttSubscription, error) {
+	fingerprint := m.Target + "\x00" + m.Metadata
+
+	cfg, err := parseMQTTConfig(m)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mqttMu.Lock()
+	if sub, ok := e.mqttSubs[m.ID]; ok {
+		if sub.fingerprint == fingerprint {
+			e.mqttMu.Unlock()
+			return sub, nil
+		}
+		delete(e.mqttSubs, m.ID)
+		go sub.client.Disconnect(250)
+	}
+	if _, ok := e.mqttPending[m.ID]; ok {
+		e.mqttMu.Unlock()
+		return nil, errMQTTNotReady
+	}
+	sub := &mqttSubscription{fingerprint: fingerprint, started: time.Now()}
+	e.mqttPending[m.ID] = sub
+	e.mqttMu.Unlock()
+
+	monitor := m
+	handler := func(_ mqtt.Client, msg mqtt.Message) {
+		sub.mu.Lock()
+		sub.lastMessage = time.Now()
+		sub.mu.Unlock()
+		e.ingestMQTTMessage(monitor, msg.Topic(), msg.Payload())
+	}
+
+	// Resubscribe whenever the client (re)connects
+	opts := cfg.clientOptions(m, "-sub").
+		SetAutoReconnect(true).
+		SetOnConnectHandler(func(c mqtt.Client) {
+			c.Subscribe(cfg.Topic, cfg.QoS, handler)
+		})
+	sub.client = mqtt.NewClient(opts)
+
+	token := sub.client.Connect()
+	connected := token.WaitTimeout(mqttTimeout)
+
+	e.mqttMu.Lock()
+	defer e.mqttMu.Unlock()
+	// Pruned while connecting: the monitor was deleted, paused or changed
+	current := e.mqttPending[m.ID] == sub
+	if current {
+		delete(e.mqttPending, m.ID)
+	}
+	switch {
+	case !current:
+		go sub.client.Disconnect(250)
+		return nil, errMQTTNotReady
+	case !connected:
+		go sub.client.Disconnect(0)
+		return nil, errors.New("MQTT connect timeout")
+	case token.Error() != nil:
+		return nil, fmt.Errorf("MQTT connect failed: %v", token.Error())
+	}
+
+	e.mqttSubs[m.ID] = sub
+	return sub, nil
+}
+
+// ingestMQTTMessage records a message as a push heartbeat.
+func (e *Engine) ingestMQTTMessage(m Monitor, topic string, payload []byte) {
+	status, msg, data := mqttPayload(topic, payload)
+	if err := e.recordPush(m, pushSignalPing, status, msg, data); err != nil {
+		log.Printf("Failed to record MQTT message for monitor %s: %v", m.ID, err)
+	}
+}
+
+// mqttPayload maps a message to push fields. JSON object payloads are stored as
+// data, with optional "status" and "msg" fields handled like the push API; any
+// other payload is stored as "payload".
+func mqttPayload(topic string, payload []byte) (status, msg string, data map[string]interface{}) {
+	data = make(map[string]interface{})
+	var obj map[string]interface{}
+	if err := json.Unmarshal(payload, &obj); err == nil {
+		for k, v := range obj {
+			data[k] = v
+		}
+		status, _ = obj["status"].(string)
+		msg, _ = obj["msg"].(string)
+		delete(data, "status")
+		delete(data, "msg")
+	} else {
+		data["payload"] = strings.TrimSpace(string(payload))
+	}
+	data["topic"] = topic
+	return status, msg, data
+}
+
+// pruneMQTTSubscriptions disconnects subscriptions of monitors that were
+// deleted, paused or switched to another mode. Disconnecting runs in the
+// background so the scheduler tick is not held up.
+func (e *Engine) pruneMQTTSubscriptions(active map[string]bool) {
+	e.mqttMu.Lock()
+	defer e.mqttMu.Unlock()
+	for id, sub := range e.mqttSubs {
+		if !active[id] {
+			delete(e.mqttSubs, id)
+			go sub.client.Disconnect(250)
+		}
+	}
+	for id := range e.mqttPending {
+		if !active[id] {
+			// Disconnected by the check once its connect returns
+			delete(e.mqttPending, id)
+		}
+	}
+}
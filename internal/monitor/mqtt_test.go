@@ -0,0 +1,98 @@
+package monitor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMQTTConfig(t *testing.T) {
+	tests := []struct {
+		metadata string
+		mode     string
+		topic    string
+		qos      byte
+	}{
+		{"", mqttModeConnect, "", 0},
+		{`{"mode":"connect","qos":1}`, mqttModeConnect, "", 1},
+		{`{"mode":"roundtrip"}`, mqttModeRoundtrip, "aeromonitor/m1/ping", 0},
+		{`{"mode":"roundtrip","topic":"health/echo","qos":2}`, mqttModeRoundtrip, "health/echo", 2},
+		{`{"mode":"subscribe","topic":"sensors/+/temp"}`, mqttModeSubscribe, "sensors/+/temp", 0},
+	}
+	for _, tt := range tests {
+		cfg, err := parseMQTTConfig(Monitor{ID: "m1", Metadata: tt.metadata})
+		if err != nil {
+			t.Errorf("parseMQTTConfig(%q): %v", tt.metadata, err)
+			continue
+		}
+		if cfg.Mode != tt.mode || cfg.Topic != tt.topic || cfg.QoS != tt.qos {
+			t.Errorf("parseMQTTConfig(%q) = %q %q qos %d, want %q %q qos %d", tt.metadata,
+				cfg.Mode, cfg.Topic, cfg.QoS, tt.mode, tt.topic, tt.qos)
+		}
+	}
+}
+
+func TestParseMQTTConfigErrors(t *testing.T) {
+	for _, metadata := range []string{
+		`{"mode":"subscribe"}`,
+		`{"mode":"publish"}`,
+		`{"qos":3}`,
+	} {
+		if _, err := parseMQTTConfig(Monitor{ID: "m1", Metadata: metadata}); err == nil {
+			t.Errorf("parseMQTTConfig(%q): expected an error", metadata)
+		}
+	}
+}
+
+func TestIsMQTTSubscription(t *testing.T) {
+	tests := []struct {
+		metadata string
+		want     bool
+	}{
+		{`{"mode":"subscribe","topic":"a/b"}`, true},
+		{`{"mode":"subscribe"}`, false},
+		{`{"mode":"roundtrip"}`, false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isMQTTSubscription(Monitor{ID: "m1", Metadata: tt.metadata}); got != tt.want {
+			t.Errorf("isMQTTSubscription(%q) = %v, want %v", tt.metadata, got, tt.want)
+		}
+	}
+}
+
+func TestMQTTPayload(t *testing.T) {
+	tests := []struct {
+		payload string
+		status  string
+		msg     string
+		data    map[string]interface{}
+	}{
+		{
+			`{"temperature":21.5,"status":"down","msg":"sensor fault"}`, "down", "sensor fault",
+			map[string]interface{}{"temperature": 21.5, "topic": "t"},
+		},
+		{
+			`{"status":"up"}`, "up", "",
+			map[string]interface{}{"topic": "t"},
+		},
+		{
+			`{"status":1,"msg":true}`, "", "",
+			map[string]interface{}{"topic": "t"},
+		},
+		{
+			" 42\n", "", "",
+			map[string]interface{}{"payload": "42", "topic": "t"},
+		},
+		{
+			`["a","b"]`, "", "",
+			map[string]interface{}{"payload": `["a","b"]`, "topic": "t"},
+		},
+	}
+	for _, tt := range tests {
+		status, msg, data := mqttPayload("t", []byte(tt.payload))
+		if status != tt.status || msg != tt.msg || !reflect.DeepEqual(data, tt.data) {
+			t.Errorf("mqttPayload(%q) = %q %q %v, want %q %q %v", tt.payload,
+				status, msg, data, tt.status, tt.msg, tt.data)
+		}
+	}
+}
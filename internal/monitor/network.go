@@ -0,0 +1,354 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// IP families for ip_family
+const (
+	ipFamilyIPv4 = "ipv4"
+	ipFamilyIPv6 = "ipv6"
+)
+
+// dialFunc opens a connection like net.Dialer.DialContext.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// networkConfig holds the per-monitor connection settings shared by the
+// http, http_flow, prometheus, websocket, file_update and tcp monitors.
+type networkConfig struct {
+	Proxy     string `json:"proxy"`      // http://, https:// or socks5:// URL, credentials allowed
+	DNSServer string `json:"dns_server"` // host[:port] of the resolver to use
+	ResolveTo string `json:"resolve_to"` // Connect to this IP instead of resolving the target host
+	SourceIP  string `json:"source_ip"`  // Local address to connect from
+	Interface string `json:"interface"`  // Connect from the first address of this interface
+	IPFamily  string `json:"ip_family"`  // "ipv4" or "ipv6", default any
+
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	CACert             string `json:"ca_cert"`     // PEM bundle added to the system roots
+	ClientCert         string `json:"client_cert"` // PEM, for mTLS
+	ClientKey          string `json:"client_key"`  // PEM, for mTLS
+}
+
+func parseNetworkConfig(metadata string) (networkConfig, error) {
+	var cfg networkConfig
+	json.Unmarshal([]byte(metadata), &cfg)
+
+	if cfg.Proxy != "" {
+		u, err := url.Parse(cfg.Proxy)
+		if err != nil || u.Host == "" {
+			return cfg, fmt.Errorf("invalid proxy %q", cfg.Proxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return cfg, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+		}
+		if cfg.DNSServer != "" || cfg.ResolveTo != "" {
+			return cfg, errors.New("dns_server and resolve_to cannot be combined with a proxy, which resolves the target itself")
+		}
+	}
+	if cfg.ResolveTo != "" && net.ParseIP(cfg.ResolveTo) == nil {
+		return cfg, fmt.Errorf("resolve_to must be an IP address, got %q", cfg.ResolveTo)
+	}
+	if cfg.SourceIP != "" && net.ParseIP(cfg.SourceIP) == nil {
+		return cfg, fmt.Errorf("invalid source_ip %q", cfg.SourceIP)
+	}
+	if cfg.SourceIP != "" && cfg.Interface != "" {
+		return cfg, errors.New("set either source_ip or interface, not both")
+	}
+	switch cfg.IPFamily {
+	case "", ipFamilyIPv4, ipFamilyIPv6:
+	default:
+		return cfg, fmt.Errorf("invalid ip_family %q", cfg.IPFamily)
+	}
+	if _, err := cfg.tlsConfig(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// isDefault reports whether the system defaults apply.
+func (cfg networkConfig) isDefault() bool {
+	return cfg == networkConfig{}
+}
+
+func (cfg networkConfig) tlsConfig() (*tls.Config, error) {
+	if !cfg.InsecureSkipVerify && cfg.CACert == "" && cfg.ClientCert == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CACert != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(cfg.CACert)) {
+			return nil, errors.New("invalid ca_cert")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.ClientCert != "" {
+		cert, err := tls.X509KeyPair([]byte(cfg.ClientCert), []byte(cfg.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// network narrows "tcp"/"udp" to the configured IP family.
+func (cfg networkConfig) network(network string) string {
+	switch cfg.IPFamily {
+	case ipFamilyIPv4:
+		return strings.TrimRight(network, "46") + "4"
+	case ipFamilyIPv6:
+		return strings.TrimRight(network, "46") + "6"
+	}
+	return network
+}
+
+func (cfg networkConfig) localAddr(network string) (net.Addr, error) {
+	var ip net.IP
+	switch {
+	case cfg.SourceIP != "":
+		ip = net.ParseIP(cfg.SourceIP)
+	case cfg.Interface != "":
+		iface, err := net.InterfaceByName(cfg.Interface)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			isV4 := ipNet.IP.To4() != nil
+			if cfg.IPFamily == ipFamilyIPv6 {
+				if !isV4 {
+					ip = ipNet.IP
+					break
+				}
+				continue
+			}
+			if isV4 {
+				ip = ipNet.IP
+				break
+			}
+			if ip == nil && cfg.IPFamily == "" {
+				// Fall back to IPv6 when the interface has no IPv4 address
+				ip = ipNet.IP
+			}
+		}
+		if ip == nil {
+			return nil, fmt.Errorf("interface %s has no usable address", cfg.Interface)
+		}
+	default:
+		return nil, nil
+	}
+	if strings.HasPrefix(network, "udp") {
+		return &net.UDPAddr{IP: ip}, nil
+	}
+	return &net.TCPAddr{IP: ip}, nil
+}
+
+// directDialer connects without a proxy, applying the resolver, source
+// address, IP family and resolve_to settings.
+func (cfg networkConfig) directDialer(timeout time.Duration) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		d := &net.Dialer{Timeout: timeout}
+		local, err := cfg.localAddr(network)
+		if err != nil {
+			return nil, err
+		}
+		if local != nil {
+			d.LocalAddr = local
+		}
+		if cfg.DNSServer != "" {
+			server := withDefaultPort(cfg.DNSServer, "53")
+			d.Resolver = &net.Resolver{
+				PreferGo: true,
+				Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+					var rd net.Dialer
+					return rd.DialContext(ctx, network, server)
+				},
+			}
+		}
+		if cfg.ResolveTo != "" {
+			if _, port, err := net.SplitHostPort(addr); err == nil {
+				addr = net.JoinHostPort(cfg.ResolveTo, port)
+			}
+		}
+		return d.DialContext(ctx, cfg.network(network), addr)
+	}
+}
+
+// dialer returns the dial function for raw connections (tcp monitors and
+// protocols layered on them), tunnelling through the proxy when configured.
+func (cfg networkConfig) dialer(timeout time.Duration) (dialFunc, error) {
+	direct := cfg.directDialer(timeout)
+	if cfg.Proxy == "" {
+		return direct, nil
+	}
+	u, err := url.Parse(cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if u.User != nil {
+			password, _ := u.User.Password()
+			auth = &proxy.Auth{User: u.User.Username(), Password: password}
+		}
+		socks, err := proxy.SOCKS5("tcp", u.Host, auth, contextDialer(direct))
+		if err != nil {
+			return nil, err
+		}
+		return socks.(proxy.ContextDialer).DialContext, nil
+	}
+
+	// HTTP proxies tunnel raw connections with CONNECT
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		port := u.Port()
+		if port == "" {
+			port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+		}
+		conn, err := direct(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+		if err != nil {
+			return nil, fmt.Errorf("proxy: %v", err)
+		}
+		if u.Scheme == "https" {
+			tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("proxy: %v", err)
+			}
+			conn = tlsConn
+		}
+		tunnel, err := httpConnect(ctx, conn, u, addr)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tunnel, nil
+	}, nil
+}
+
+// httpConnect asks an HTTP proxy to open a tunnel to addr.
+func httpConnect(ctx context.Context, conn net.Conn, proxyURL *url.URL, addr string) (net.Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr)
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		req += "Proxy-Authorization: Basic " + credentials + "\r\n"
+	}
+	if _, err := conn.Write([]byte(req + "\r\n")); err != nil {
+		return nil, fmt.Errorf("proxy: %v", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy refused CONNECT: %s", resp.Status)
+	}
+	if br.Buffered() > 0 {
+		// The target spoke first (e.g. a banner) and part of it is buffered
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+type contextDialer dialFunc
+
+func (d contextDialer) Dial(network, addr string) (net.Conn, error) {
+	return d(context.Background(), network, addr)
+}
+
+func (d contextDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return d(ctx, network, addr)
+}
+
+// transport builds an HTTP transport honouring the settings. Connections are
+// not kept alive since the transport lives for one check.
+func (cfg networkConfig) transport() (*http.Transport, error) {
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DisableKeepAlives = true
+	t.DialContext = cfg.directDialer(30 * time.Second)
+	t.TLSClientConfig = tlsConfig
+	if cfg.Proxy != "" {
+		u, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	return t, nil
+}
+
+// monitorHTTPClient returns the client for HTTP-based checks of m: the shared
+// client, or a dedicated one when the monitor has network settings.
+func (e *Engine) monitorHTTPClient(m Monitor) (*http.Client, error) {
+	cfg, err := parseNetworkConfig(m.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.isDefault() {
+		return e.httpClient, nil
+	}
+	t, err := cfg.transport()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: t, Timeout: e.httpClient.Timeout}, nil
+}
+
+// monitorDialer returns the dial function for raw connections of m.
+func monitorDialer(m Monitor, timeout time.Duration) (dialFunc, error) {
+	cfg, err := parseNetworkConfig(m.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.dialer(timeout)
+}
@@ -0,0 +1,127 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"aeromonitor/internal/notification"
+)
+
+// A failed summary is retried with exponential backoff and dropped after
+// queueMaxAttempts, so a broken channel does not retry every tick forever.
+const (
+	queueRetryBase   = time.Minute
+	queueRetryMax    = time.Hour
+	queueMaxAttempts = 8
+)
+
+type queuedNotification struct {
+	ID             int64      `db:"id"`
+	NotificationID string     `db:"notification_id"`
+	Title          string     `db:"title"`
+	Message        string     `db:"message"`
+	CreatedAt      time.Time  `db:"created_at"`
+	Attempts       int        `db:"attempts"`
+	NextAttempt    *time.Time `db:"next_attempt"`
+}
+
+// queueRetryDelay is the wait after the given number of failed attempts.
+func queueRetryDelay(attempts int) time.Duration {
+	delay := queueRetryBase
+	for i := 1; i < attempts && delay < queueRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, queueRetryMax)
+}
+
+// queueNotification stores an event suppressed by a channel schedule so it can
+// be delivered as part of a summary when the quiet window ends.
+func (e *Engine) queueNotification(notificationID, title, message string) {
+	_, err := e.db.Exec("INSERT INTO notification_queue (notification_id, title, message) VALUES (?, ?, ?)", notificationID, title, message)
+	if err != nil {
+		log.Printf("Failed to queue notification for channel %s: %v", notificationID, err)
+	}
+}
+
+// flushNotificationQueue delivers a summary of queued events for every channel
+// whose schedule allows delivery again.
+func (e *Engine) flushNotificationQueue() {
+	var channelIDs []string
+	if err := e.db.Select(&channelIDs, "SELECT DISTINCT notification_id FROM notification_queue"); err != nil {
+		log.Printf("Failed to read notification queue: %v", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, id := range channelIDs {
+		var n struct {
+			Type     string `db:"type"`
+			Config   string `db:"config"`
+			Schedule string `db:"schedule"`
+		}
+		if err := e.db.Get(&n, "SELECT type, config, COALESCE(schedule, '') as schedule FROM notifications WHERE id = ?", id); err != nil {
+			// Channel was removed, drop whatever it had queued
+			e.db.Exec("DELETE FROM notification_queue WHERE notification_id = ?", id)
+			continue
+		}
+
+		schedule, err := notification.ParseSchedule(n.Schedule)
+		if err == nil && !schedule.Allows(now, nil) {
+			continue
+		}
+
+		var items []queuedNotification
+		if err := e.db.Select(&items, "SELECT id, notification_id, title, message, created_at, COALESCE(attempts, 0) as attempts, next_attempt FROM notification_queue WHERE notification_id = ? ORDER BY id", id); err != nil || len(items) == 0 {
+			continue
+		}
+
+		// The summary is retried as a whole; events queued since the last
+		// attempt join it without resetting the backoff
+		attempts := 0
+		var next time.Time
+		for _, item := range items {
+			attempts = max(attempts, item.Attempts)
+			if item.NextAttempt != nil && item.NextAttempt.After(next) {
+				next = *item.NextAttempt
+			}
+		}
+		if now.Before(next) {
+			continue
+		}
+
+		lastID := items[len(items)-1].ID
+		title, message := summarizeQueued(e.getAppTitle(), items, schedule)
+		if err := notification.SendNotification(n.Type, n.Config, title, message, map[string]string{}); err != nil {
+			attempts++
+			if attempts >= queueMaxAttempts {
+				log.Printf("Dropping %d queued notifications for channel %s after %d failed attempts: %v", len(items), id, attempts, err)
+				e.db.Exec("DELETE FROM notification_queue WHERE notification_id = ? AND id <= ?", id, lastID)
+				continue
+			}
+			delay := queueRetryDelay(attempts)
+			log.Printf("Failed to send queued notification summary for channel %s, retrying in %s: %v", id, delay, err)
+			e.db.Exec("UPDATE notification_queue SET attempts = ?, next_attempt = ? WHERE notification_id = ? AND id <= ?", attempts, now.Add(delay), id, lastID)
+			continue
+		}
+
+		e.db.Exec("DELETE FROM notification_queue WHERE notification_id = ? AND id <= ?", id, lastID)
+	}
+}
+
+func summarizeQueued(appTitle string, items []queuedNotification, schedule *notification.Schedule) (string, string) {
+	loc := time.UTC
+	if schedule != nil && schedule.Timezone != "" {
+		if l, err := time.LoadLocation(schedule.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	title := fmt.Sprintf("%s: %d events during quiet hours", appTitle, len(items))
+	var b strings.Builder
+	for _, item := range items {
+		fmt.Fprintf(&b, "[%s] %s\n", item.CreatedAt.In(loc).Format("2006-01-02 15:04"), item.Message)
+	}
+	return title, strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,405 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httputil"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ntripDefaultPort     = "2101"
+	ntripConnectTimeout  = 10 * time.Second
+	ntripDefaultDuration = 10 * time.Second
+)
+
+// ntripConfig is the NTRIP-specific part of the monitor metadata. The target is
+// the caster host[:port].
+type ntripConfig struct {
+	Mountpoints []string `json:"mountpoints"` // Must be listed in the sourcetable
+	Mountpoint  string   `json:"mountpoint"`  // Stream to subscribe to
+	Username    string   `json:"username"`
+	Password    string   `json:"password"`
+	Version     int      `json:"ntrip_version"` // 1 (default) or 2
+	Duration    int      `json:"duration"`      // Seconds to listen to the stream
+	MinFrames   int      `json:"min_frames"`    // Valid RTCM3 frames required, default 1
+	GGA         string   `json:"gga"`           // NMEA GGA sent after connecting (VRS mountpoints)
+}
+
+func parseNTRIPConfig(metadata string) (ntripConfig, error) {
+	var cfg ntripConfig
+	if metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &cfg); err != nil {
+			return cfg, fmt.Errorf("invalid ntrip metadata: %v", err)
+		}
+	}
+	switch cfg.Version {
+	case 0, 1, 2:
+	default:
+		return cfg, fmt.Errorf("invalid ntrip_version %d, want 1 or 2", cfg.Version)
+	}
+	if cfg.Duration < 0 || cfg.MinFrames < 0 {
+		return cfg, errors.New("duration and min_frames cannot be negative")
+	}
+	if strings.ContainsAny(cfg.Mountpoint, "/ ") {
+		return cfg, fmt.Errorf("invalid mountpoint %q", cfg.Mountpoint)
+	}
+	if gga := strings.TrimSpace(cfg.GGA); gga != "" && !strings.HasPrefix(gga, "$") {
+		return cfg, errors.New("gga must be an NMEA sentence starting with $")
+	}
+	return cfg, nil
+}
+
+func (cfg ntripConfig) duration() time.Duration {
+	if cfg.Duration > 0 {
+		return time.Duration(cfg.Duration) * time.Second
+	}
+	return ntripDefaultDuration
+}
+
+func (e *Engine) checkNTRIP(m Monitor) Result {
+	cfg, err := parseNTRIPConfig(m.Metadata)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}
+	}
+	addr := withDefaultPort(m.Target, ntripDefaultPort)
+
+	start := time.Now()
+	data := make(map[string]interface{})
+
+	// 1. Sourcetable: always fetched when no stream is configured
+	if len(cfg.Mountpoints) > 0 || cfg.Mountpoint == "" {
+		mounts, err := fetchSourcetable(e.ctx, addr, cfg)
+		if err != nil {
+			return Result{MonitorID: m.ID, Status: "down", Latency: int(time.Since(start).Milliseconds()), Message: fmt.Sprintf("Sourcetable: %v", err)}
+		}
+		data["mountpoints"] = len(mounts)
+
+		var missing []string
+		for _, want := range cfg.Mountpoints {
+			if !mounts[want] {
+				missing = append(missing, want)
+			}
+		}
+		if len(missing) > 0 {
+			data["missing_mountpoints"] = missing
+			return Result{
+				MonitorID: m.ID,
+				Status:    "down",
+				Latency:   int(time.Since(start).Milliseconds()),
+				Message:   fmt.Sprintf("Mountpoints missing from sourcetable: %s", strings.Join(missing, ", ")),
+				Data:      marshalData(data),
+			}
+		}
+	}
+
+	if cfg.Mountpoint == "" {
+		return Result{
+			MonitorID: m.ID,
+			Status:    "up",
+			Latency:   int(time.Since(start).Milliseconds()),
+			Message:   fmt.Sprintf("Sourcetable lists %d mountpoints", data["mountpoints"]),
+			Data:      marshalData(data),
+		}
+	}
+
+	// 2. Stream: subscribe and validate RTCM3 frames
+	stats, err := streamRTCM(e.ctx, addr, cfg)
+	for k, v := range stats.data() {
+		data[k] = v
+	}
+	latency := int(time.Since(start).Milliseconds())
+	if stats.firstFrame > 0 {
+		latency = int(stats.firstFrame.Milliseconds())
+	}
+
+	minFrames := cfg.MinFrames
+	if minFrames <= 0 {
+		minFrames = 1
+	}
+	switch {
+	case err != nil && stats.frames == 0:
+		return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: fmt.Sprintf("Mountpoint %s: %v", cfg.Mountpoint, err), Data: marshalData(data)}
+	case stats.frames < minFrames:
+		return Result{
+			MonitorID: m.ID,
+			Status:    "down",
+			Latency:   latency,
+			Message:   fmt.Sprintf("Mountpoint %s: %d valid RTCM3 frames in %s, expected %d", cfg.Mountpoint, stats.frames, cfg.duration(), minFrames),
+			Data:      marshalData(data),
+		}
+	}
+
+	return Result{
+		MonitorID: m.ID,
+		Status:    "up",
+		Latency:   latency,
+		Message:   fmt.Sprintf("Mountpoint %s: %d RTCM3 frames, %.0f B/s", cfg.Mountpoint, stats.frames, stats.rate()),
+		Data:      marshalData(data),
+	}
+}
+
+// ntripConn closes the connection when the context ends, so a stream does not
+// hold up shutdown for the rest of its listening window.
+type ntripConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func (c ntripConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// ntripRequest connects to the caster, sends a GET for path and returns a
+// reader positioned at the response body along with the status line.
+func ntripRequest(ctx context.Context, addr, path string, cfg ntripConfig) (net.Conn, io.Reader, string, error) {
+	d := net.Dialer{Timeout: ntripConnectTimeout}
+	raw, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	conn := ntripConn{Conn: raw, stop: context.AfterFunc(ctx, func() { raw.Close() })}
+	conn.SetDeadline(time.Now().Add(ntripConnectTimeout))
+
+	host, _, _ := net.SplitHostPort(addr)
+	var req strings.Builder
+	if cfg.Version == 2 {
+		fmt.Fprintf(&req, "GET /%s HTTP/1.1\r\nHost: %s\r\nNtrip-Version: Ntrip/2.0\r\n", path, host)
+	} else {
+		fmt.Fprintf(&req, "GET /%s HTTP/1.0\r\n", path)
+	}
+	req.WriteString("User-Agent: NTRIP AeroMonitor/1.0\r\nConnection: close\r\n")
+	if cfg.Username != "" || cfg.Password != "" {
+		fmt.Fprintf(&req, "Authorization: Basic %s\r\n", base64.StdEncoding.EncodeToString([]byte(cfg.Username+":"+cfg.Password)))
+	}
+	req.WriteString("\r\n")
+	if _, err := conn.Write([]byte(req.String())); err != nil {
+		conn.Close()
+		return nil, nil, "", err
+	}
+
+	br := bufio.NewReader(conn)
+	tp := textproto.NewReader(br)
+	statusLine, err := tp.ReadLine()
+	if err != nil {
+		conn.Close()
+		return nil, nil, "", fmt.Errorf("reading response: %v", err)
+	}
+
+	// NTRIP v1 "ICY 200 OK" streams start right after the status line; v1
+	// sourcetables and v2 responses carry headers first.
+	if strings.HasPrefix(statusLine, "ICY ") {
+		return conn, br, statusLine, nil
+	}
+	header, err := tp.ReadMIMEHeader()
+	if err != nil && !errors.Is(err, io.EOF) {
+		conn.Close()
+		return nil, nil, "", fmt.Errorf("reading headers: %v", err)
+	}
+	var body io.Reader = br
+	if strings.EqualFold(header.Get("Transfer-Encoding"), "chunked") {
+		body = httputil.NewChunkedReader(br)
+	}
+	return conn, body, statusLine, nil
+}
+
+func statusCode(statusLine string) int {
+	fields := strings.Fields(statusLine)
+	if len(fields) < 2 {
+		return 0
+	}
+	code, _ := strconv.Atoi(fields[1])
+	return code
+}
+
+// fetchSourcetable returns the set of STR mountpoints advertised by the caster.
+func fetchSourcetable(ctx context.Context, addr string, cfg ntripConfig) (map[string]bool, error) {
+	conn, body, statusLine, err := ntripRequest(ctx, addr, "", cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if statusCode(statusLine) != 200 {
+		return nil, fmt.Errorf("unexpected response %q", statusLine)
+	}
+
+	mounts := make(map[string]bool)
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "ENDSOURCETABLE" {
+			return mounts, nil
+		}
+		if strings.HasPrefix(line, "STR;") {
+			if fields := strings.Split(line, ";"); len(fields) > 1 {
+				mounts[fields[1]] = true
+			}
+		}
+	}
+	if len(mounts) > 0 {
+		// Some casters close without ENDSOURCETABLE
+		return mounts, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("empty sourcetable")
+}
+
+type rtcmStats struct {
+	frames       int
+	crcErrors    int
+	bytes        int
+	messageTypes map[int]int
+	firstFrame   time.Duration
+	elapsed      time.Duration
+}
+
+func (s rtcmStats) rate() float64 {
+	if s.elapsed <= 0 {
+		return 0
+	}
+	return float64(s.bytes) / s.elapsed.Seconds()
+}
+
+func (s rtcmStats) data() map[string]interface{} {
+	types := make(map[string]int, len(s.messageTypes))
+	keys := make([]int, 0, len(s.messageTypes))
+	for t, n := range s.messageTypes {
+		types[strconv.Itoa(t)] = n
+		keys = append(keys, t)
+	}
+	sort.Ints(keys)
+	list := make([]string, len(keys))
+	for i, k := range keys {
+		list[i] = strconv.Itoa(k)
+	}
+	return map[string]interface{}{
+		"frames":             s.frames,
+		"crc_errors":         s.crcErrors,
+		"bytes":              s.bytes,
+		"bytes_per_sec":      int(s.rate()),
+		"message_types":      strings.Join(list, ","),
+		"message_type_count": types,
+	}
+}
+
+// streamRTCM subscribes to the mountpoint and counts valid RTCM3 frames for the
+// configured duration.
+func streamRTCM(ctx context.Context, addr string, cfg ntripConfig) (rtcmStats, error) {
+	stats := rtcmStats{messageTypes: make(map[int]int)}
+
+	conn, body, statusLine, err := ntripRequest(ctx, addr, cfg.Mountpoint, cfg)
+	if err != nil {
+		return stats, err
+	}
+	defer conn.Close()
+
+	switch {
+	case strings.HasPrefix(statusLine, "SOURCETABLE"):
+		return stats, errors.New("mountpoint not found (caster returned sourcetable)")
+	case statusCode(statusLine) == 401:
+		return stats, errors.New("unauthorized")
+	case statusCode(statusLine) != 200:
+		return stats, fmt.Errorf("unexpected response %q", statusLine)
+	}
+
+	if cfg.GGA != "" {
+		conn.Write([]byte(strings.TrimSpace(cfg.GGA) + "\r\n"))
+	}
+
+	start := time.Now()
+	conn.SetDeadline(start.Add(cfg.duration()))
+
+	var buf []byte
+	chunk := make([]byte, 4096)
+	for {
+		n, err := body.Read(chunk)
+		stats.bytes += n
+		buf = append(buf, chunk[:n]...)
+		buf = stats.consume(buf, start)
+		if err != nil {
+			stats.elapsed = time.Since(start)
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				// Listening window is over
+				return stats, nil
+			}
+			if stats.frames == 0 {
+				return stats, fmt.Errorf("stream closed: %v", err)
+			}
+			return stats, nil
+		}
+	}
+}
+
+// consume parses complete RTCM3 frames from buf and returns the unparsed rest.
+// Frame layout: 0xD3, 6 reserved bits, 10-bit length, payload, CRC-24Q.
+func (s *rtcmStats) consume(buf []byte, start time.Time) []byte {
+	for {
+		idx := bytes.IndexByte(buf, 0xD3)
+		if idx < 0 {
+			return buf[:0]
+		}
+		buf = buf[idx:]
+		if len(buf) < 3 {
+			return buf
+		}
+		length := int(buf[1]&0x03)<<8 | int(buf[2])
+		if buf[1]&0xFC != 0 {
+			// Reserved bits set: not a frame start
+			buf = buf[1:]
+			continue
+		}
+		frameLen := 3 + length + 3
+		if len(buf) < frameLen {
+			return buf
+		}
+
+		frame := buf[:frameLen]
+		crc := uint32(frame[frameLen-3])<<16 | uint32(frame[frameLen-2])<<8 | uint32(frame[frameLen-1])
+		if crc24q(frame[:frameLen-3]) != crc {
+			s.crcErrors++
+			buf = buf[1:]
+			continue
+		}
+
+		if s.frames == 0 {
+			s.firstFrame = time.Since(start)
+		}
+		s.frames++
+		if length >= 2 {
+			msgType := int(frame[3])<<4 | int(frame[4])>>4
+			s.messageTypes[msgType]++
+		}
+		buf = buf[frameLen:]
+	}
+}
+
+// crc24q computes the Qualcomm CRC-24 used by RTCM3.
+func crc24q(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= 0x1864CFB
+			}
+		}
+	}
+	return crc & 0xFFFFFF
+}
@@ -0,0 +1,233 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rtcm1005 is a station coordinates message (type 1005) with a valid CRC.
+const rtcm1005 = "d300133ed7d30202980edeef34b4bd62ac0941986f33360b98"
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// rtcmFrame builds a frame around payload with a correct CRC.
+func rtcmFrame(payload []byte) []byte {
+	frame := append([]byte{0xD3, byte(len(payload) >> 8), byte(len(payload))}, payload...)
+	crc := crc24q(frame)
+	return append(frame, byte(crc>>16), byte(crc>>8), byte(crc))
+}
+
+func TestCRC24Q(t *testing.T) {
+	tests := []struct {
+		data string
+		want uint32
+	}{
+		{"", 0},
+		{hex.EncodeToString([]byte("123456789")), 0xCDE703},
+		{rtcm1005[:len(rtcm1005)-6], 0x360B98},
+	}
+	for _, tt := range tests {
+		if got := crc24q(mustHex(t, tt.data)); got != tt.want {
+			t.Errorf("crc24q(%s) = %06X, want %06X", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestRTCMConsume(t *testing.T) {
+	frame := mustHex(t, rtcm1005)
+	msm := rtcmFrame([]byte{0x43, 0xF0, 0x00, 0x01}) // type 1087
+	empty := rtcmFrame(nil)
+	corrupt := append([]byte(nil), msm...)
+	corrupt[4] ^= 0xFF
+
+	cat := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+	tests := []struct {
+		name      string
+		chunks    [][]byte
+		frames    int
+		crcErrors int
+		types     map[int]int
+		rest      int
+	}{
+		{"one frame", [][]byte{frame}, 1, 0, map[int]int{1005: 1}, 0},
+		{"two frames", [][]byte{cat(frame, msm)}, 2, 0, map[int]int{1005: 1, 1087: 1}, 0},
+		{"leading garbage", [][]byte{cat([]byte("ICY junk"), frame)}, 1, 0, map[int]int{1005: 1}, 0},
+		{"reserved bits set", [][]byte{cat([]byte{0xD3, 0xFC, 0x13}, frame)}, 1, 0, map[int]int{1005: 1}, 0},
+		{"bad CRC", [][]byte{cat(corrupt, frame)}, 1, 1, map[int]int{1005: 1}, 0},
+		{"split frame", [][]byte{frame[:2], frame[2:9], frame[9:]}, 1, 0, map[int]int{1005: 1}, 0},
+		{"incomplete frame", [][]byte{cat(frame, msm[:5])}, 1, 0, map[int]int{1005: 1}, 5},
+		{"empty frame", [][]byte{empty}, 1, 0, map[int]int{}, 0},
+		{"no frame", [][]byte{[]byte("no preamble here")}, 0, 0, map[int]int{}, 0},
+	}
+	for _, tt := range tests {
+		s := rtcmStats{messageTypes: make(map[int]int)}
+		var buf []byte
+		for _, c := range tt.chunks {
+			buf = s.consume(append(buf, c...), time.Now())
+		}
+		if s.frames != tt.frames || s.crcErrors != tt.crcErrors || len(buf) != tt.rest {
+			t.Errorf("%s: %d frames, %d CRC errors, %d bytes left, want %d, %d, %d",
+				tt.name, s.frames, s.crcErrors, len(buf), tt.frames, tt.crcErrors, tt.rest)
+		}
+		if len(s.messageTypes) != len(tt.types) {
+			t.Errorf("%s: message types %v, want %v", tt.name, s.messageTypes, tt.types)
+		}
+		for typ, n := range tt.types {
+			if s.messageTypes[typ] != n {
+				t.Errorf("%s: message types %v, want %v", tt.name, s.messageTypes, tt.types)
+			}
+		}
+	}
+}
+
+func TestParseNTRIPConfig(t *testing.T) {
+	for _, metadata := range []string{
+		"",
+		`{"mountpoints":["RTCM3"]}`,
+		`{"mountpoint":"RTCM3","ntrip_version":2,"duration":5,"min_frames":3}`,
+		`{"mountpoint":"VRS","gga":"$GPGGA,000000.00,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*47"}`,
+	} {
+		if _, err := parseNTRIPConfig(metadata); err != nil {
+			t.Errorf("parseNTRIPConfig(%q): %v", metadata, err)
+		}
+	}
+	for _, metadata := range []string{
+		`{"mountpoint":`,
+		`{"ntrip_version":3}`,
+		`{"duration":-1}`,
+		`{"min_frames":-1}`,
+		`{"mountpoint":"a/b"}`,
+		`{"gga":"GPGGA,000000.00"}`,
+	} {
+		if _, err := parseNTRIPConfig(metadata); err == nil {
+			t.Errorf("parseNTRIPConfig(%q): expected an error", metadata)
+		}
+	}
+}
+
+// serveNTRIP answers one connection with reply and keeps it open when hold is
+// set. It returns the caster address and the request line received.
+func serveNTRIP(t *testing.T, reply []byte, hold bool) (string, <-chan string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	requests := make(chan string, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		requests <- strings.TrimSpace(line)
+		conn.Write(reply)
+		if hold {
+			time.Sleep(10 * time.Second)
+		}
+	}()
+	return lis.Addr().String(), requests
+}
+
+func TestFetchSourcetable(t *testing.T) {
+	tests := []struct {
+		name   string
+		reply  string
+		mounts []string
+		err    bool
+	}{
+		{
+			"v1",
+			"SOURCETABLE 200 OK\r\nServer: Caster\r\nContent-Type: text/plain\r\n\r\n" +
+				"CAS;caster.example.com;2101;Caster;;0;DEU;48.1;11.5;0.0.0.0;0;\r\n" +
+				"STR;RTCM3;Munich;RTCM 3.2;1005(10),1077(1);2;GPS+GLO;EUREF;DEU;48.1;11.5;0;0;;none;B;N;9600;\r\n" +
+				"STR;VRS;Munich;RTCM 3.2;;2;GPS;EUREF;DEU;48.1;11.5;1;0;;none;B;N;9600;\r\n" +
+				"ENDSOURCETABLE\r\n",
+			[]string{"RTCM3", "VRS"}, false,
+		},
+		{
+			"v2 chunked",
+			"HTTP/1.1 200 OK\r\nNtrip-Version: Ntrip/2.0\r\nTransfer-Encoding: chunked\r\n\r\n" +
+				"2a\r\nSTR;RTCM3;Munich;RTCM 3.2;;2;GPS;;DEU;0;0\r\n\r\n" +
+				"10\r\nENDSOURCETABLE\r\n\r\n0\r\n\r\n",
+			[]string{"RTCM3"}, false,
+		},
+		{
+			"no ENDSOURCETABLE",
+			"SOURCETABLE 200 OK\r\n\r\nSTR;RTCM3;Munich\r\n",
+			[]string{"RTCM3"}, false,
+		},
+		{"no streams", "SOURCETABLE 200 OK\r\n\r\nENDSOURCETABLE\r\n", nil, false},
+		{"closed early", "SOURCETABLE 200 OK\r\n\r\n", nil, true},
+		{"unauthorized", "HTTP/1.1 401 Unauthorized\r\n\r\n", nil, true},
+	}
+	for _, tt := range tests {
+		addr, requests := serveNTRIP(t, []byte(tt.reply), false)
+		mounts, err := fetchSourcetable(context.Background(), addr, ntripConfig{})
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if req := <-requests; req != "GET / HTTP/1.0" {
+			t.Errorf("%s: request %q", tt.name, req)
+		}
+		if len(mounts) != len(tt.mounts) {
+			t.Errorf("%s: mountpoints %v, want %v", tt.name, mounts, tt.mounts)
+		}
+		for _, m := range tt.mounts {
+			if !mounts[m] {
+				t.Errorf("%s: mountpoints %v, want %v", tt.name, mounts, tt.mounts)
+			}
+		}
+	}
+}
+
+func TestStreamRTCM(t *testing.T) {
+	frames := bytes.Repeat(mustHex(t, rtcm1005), 3)
+	addr, requests := serveNTRIP(t, append([]byte("ICY 200 OK\r\n"), frames...), false)
+	stats, err := streamRTCM(context.Background(), addr, ntripConfig{Mountpoint: "RTCM3", Duration: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req := <-requests; req != "GET /RTCM3 HTTP/1.0" {
+		t.Errorf("request %q", req)
+	}
+	if stats.frames != 3 || stats.bytes != len(frames) || stats.messageTypes[1005] != 3 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	addr, _ = serveNTRIP(t, []byte("SOURCETABLE 200 OK\r\n\r\nENDSOURCETABLE\r\n"), false)
+	if _, err := streamRTCM(context.Background(), addr, ntripConfig{Mountpoint: "MISSING"}); err == nil {
+		t.Error("expected an error for a missing mountpoint")
+	}
+}
+
+func TestStreamRTCMCancel(t *testing.T) {
+	addr, _ := serveNTRIP(t, append([]byte("ICY 200 OK\r\n"), mustHex(t, rtcm1005)...), true)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	stats, _ := streamRTCM(ctx, addr, ntripConfig{Mountpoint: "RTCM3", Duration: 30})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("stream ran for %s after the context ended", elapsed)
+	}
+	if stats.frames != 1 {
+		t.Errorf("frames = %d, want 1", stats.frames)
+	}
+}
@@ -0,0 +1,190 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultProbeTimeout = 5 * time.Second
+	maxProbeResponse    = 64 * 1024
+)
+
+// probeConfig describes an optional payload exchange for tcp and udp monitors:
+//
+//	{"send": "PING\r\n", "expect_regex": "^\\+PONG"}
+//	{"send_hex": "d300", "expect_hex": "d3"}
+//	{"expect_regex": "^SSH-2\\.0-"}  // banner check, nothing sent
+type probeConfig struct {
+	Send        string `json:"send"`
+	SendHex     string `json:"send_hex"`
+	ExpectRegex string `json:"expect_regex"`
+	ExpectHex   string `json:"expect_hex"`
+	Timeout     int    `json:"timeout"` // seconds
+}
+
+func parseProbeConfig(metadata string) (probeConfig, error) {
+	var cfg probeConfig
+	json.Unmarshal([]byte(metadata), &cfg)
+	if _, err := cfg.payload(); err != nil {
+		return cfg, err
+	}
+	if _, _, err := cfg.matchers(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+func (cfg probeConfig) timeout() time.Duration {
+	if cfg.Timeout > 0 {
+		return time.Duration(cfg.Timeout) * time.Second
+	}
+	return defaultProbeTimeout
+}
+
+func (cfg probeConfig) payload() ([]byte, error) {
+	if cfg.SendHex != "" {
+		b, err := hex.DecodeString(strings.ReplaceAll(cfg.SendHex, " ", ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid send_hex: %v", err)
+		}
+		return b, nil
+	}
+	return []byte(cfg.Send), nil
+}
+
+func (cfg probeConfig) matchers() (*regexp.Regexp, []byte, error) {
+	var re *regexp.Regexp
+	var pattern []byte
+	var err error
+	if cfg.ExpectRegex != "" {
+		if re, err = regexp.Compile(cfg.ExpectRegex); err != nil {
+			return nil, nil, fmt.Errorf("invalid expect_regex: %v", err)
+		}
+	}
+	if cfg.ExpectHex != "" {
+		if pattern, err = hex.DecodeString(strings.ReplaceAll(cfg.ExpectHex, " ", "")); err != nil {
+			return nil, nil, fmt.Errorf("invalid expect_hex: %v", err)
+		}
+	}
+	return re, pattern, nil
+}
+
+func (cfg probeConfig) expectsResponse() bool {
+	return cfg.ExpectRegex != "" || cfg.ExpectHex != ""
+}
+
+// matches reports whether the response satisfies every configured pattern.
+func (cfg probeConfig) matches(resp []byte) bool {
+	re, pattern, _ := cfg.matchers()
+	if re != nil && !re.Match(resp) {
+		return false
+	}
+	if pattern != nil && !bytes.Contains(resp, pattern) {
+		return false
+	}
+	return true
+}
+
+// exchange sends the configured payload over a stream connection and reads
+// until the response matches or the timeout expires.
+func (cfg probeConfig) exchange(conn net.Conn) ([]byte, error) {
+	conn.SetDeadline(time.Now().Add(cfg.timeout()))
+
+	payload, err := cfg.payload()
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > 0 {
+		if _, err := conn.Write(payload); err != nil {
+			return nil, fmt.Errorf("send failed: %v", err)
+		}
+	}
+	if !cfg.expectsResponse() {
+		return nil, nil
+	}
+
+	var resp []byte
+	buf := make([]byte, 4096)
+	for len(resp) < maxProbeResponse {
+		n, err := conn.Read(buf)
+		resp = append(resp, buf[:n]...)
+		if cfg.matches(resp) {
+			return resp, nil
+		}
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return resp, fmt.Errorf("no matching response within %s", cfg.timeout())
+			}
+			return resp, fmt.Errorf("response did not match: %v", err)
+		}
+	}
+	return resp, errors.New("response did not match")
+}
+
+// describeResponse renders a response for heartbeat data, as text when
+// printable and hex otherwise.
+func describeResponse(resp []byte) string {
+	const max = 256
+	if len(resp) > max {
+		resp = resp[:max]
+	}
+	for _, b := range resp {
+		if (b < 0x20 || b > 0x7e) && b != '\r' && b != '\n' && b != '\t' {
+			return hex.EncodeToString(resp)
+		}
+	}
+	return string(resp)
+}
+
+func (e *Engine) checkUDP(m Monitor) Result {
+	cfg, err := parseProbeConfig(m.Metadata)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}
+	}
+	payload, _ := cfg.payload()
+
+	start := time.Now()
+	conn, err := net.DialTimeout("udp", m.Target, cfg.timeout())
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(cfg.timeout()))
+
+	if _, err := conn.Write(payload); err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: fmt.Sprintf("send failed: %v", err)}
+	}
+
+	// Every datagram is a complete reply; keep reading until one matches
+	buf := make([]byte, maxProbeResponse)
+	for {
+		n, err := conn.Read(buf)
+		latency := int(time.Since(start).Milliseconds())
+		if err != nil {
+			msg := fmt.Sprintf("No reply within %s", cfg.timeout())
+			var netErr net.Error
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				msg = err.Error()
+			}
+			return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: msg}
+		}
+		if cfg.matches(buf[:n]) {
+			return Result{
+				MonitorID: m.ID,
+				Status:    "up",
+				Latency:   latency,
+				Message:   "UDP reply received",
+				Data:      marshalData(map[string]interface{}{"response": describeResponse(buf[:n])}),
+			}
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// prometheusConfig is the metadata of prometheus monitors. The target is the
+// base URL of a Prometheus-compatible API, e.g. http://prometheus:9090.
+type prometheusConfig struct {
+	Query      string            `json:"query"`
+	MinValue   *float64          `json:"min_value"`
+	MaxValue   *float64          `json:"max_value"`
+	AllowEmpty bool              `json:"allow_empty"` // An empty result is up instead of down
+	Username   string            `json:"username"`
+	Password   string            `json:"password"`
+	Headers    map[string]string `json:"headers"` // e.g. Authorization, X-Scope-OrgID
+}
+
+func parsePrometheusConfig(metadata string) (prometheusConfig, error) {
+	var cfg prometheusConfig
+	json.Unmarshal([]byte(metadata), &cfg)
+	if strings.TrimSpace(cfg.Query) == "" {
+		return cfg, errors.New("prometheus monitor requires a query")
+	}
+	return cfg, nil
+}
+
+// promSample is one value of a query result, labelled by its series.
+type promSample struct {
+	Series string
+	Value  float64
+}
+
+type promResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+func (e *Engine) checkPrometheus(m Monitor) Result {
+	cfg, err := parsePrometheusConfig(m.Metadata)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}
+	}
+
+	client, err := e.monitorHTTPClient(m)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}
+	}
+
+	endpoint := strings.TrimRight(m.Target, "/") + "/api/v1/query?" + url.Values{"query": {cfg.Query}}.Encode()
+	req, err := http.NewRequestWithContext(e.ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: fmt.Sprintf("Request creation failed: %v", err)}
+	}
+	if cfg.Username != "" || cfg.Password != "" {
+		req.SetBasicAuth(cfg.Username, cfg.Password)
+	}
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Latency: int(time.Since(start).Milliseconds()), Message: err.Error()}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFlowResponse))
+	latency := int(time.Since(start).Milliseconds())
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: fmt.Sprintf("Reading response failed: %v", err)}
+	}
+
+	var pr promResponse
+	if err := json.Unmarshal(body, &pr); err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: fmt.Sprintf("Invalid response (%s)", resp.Status)}
+	}
+	if pr.Status != "success" {
+		return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: fmt.Sprintf("Query failed: %s: %s", pr.ErrorType, pr.Error)}
+	}
+
+	samples, err := parsePromResult(pr.Data.ResultType, pr.Data.Result)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: err.Error()}
+	}
+
+	data := map[string]interface{}{"series": len(samples)}
+	if len(samples) == 1 {
+		data["value"] = jsonNumber(samples[0].Value)
+	} else if len(samples) > 1 {
+		values := make(map[string]interface{}, len(samples))
+		for _, s := range samples {
+			values[s.Series] = jsonNumber(s.Value)
+		}
+		data["values"] = values
+	}
+
+	if len(samples) == 0 {
+		if cfg.AllowEmpty {
+			return Result{MonitorID: m.ID, Status: "up", Latency: latency, Message: "Query returned no data", Data: marshalData(data)}
+		}
+		return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: "Query returned no data", Data: marshalData(data)}
+	}
+
+	for _, s := range samples {
+		var violation string
+		switch {
+		case math.IsNaN(s.Value) && (cfg.MinValue != nil || cfg.MaxValue != nil):
+			violation = "is NaN"
+		case cfg.MinValue != nil && s.Value < *cfg.MinValue:
+			violation = fmt.Sprintf("%g is below %g", s.Value, *cfg.MinValue)
+		case cfg.MaxValue != nil && s.Value > *cfg.MaxValue:
+			violation = fmt.Sprintf("%g is above %g", s.Value, *cfg.MaxValue)
+		}
+		if violation != "" {
+			msg := "Value " + violation
+			if s.Series != "" {
+				msg = fmt.Sprintf("%s %s", s.Series, violation)
+			}
+			return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: msg, Data: marshalData(data)}
+		}
+	}
+
+	msg := fmt.Sprintf("Value %g", samples[0].Value)
+	if len(samples) > 1 {
+		msg = fmt.Sprintf("%d series within thresholds", len(samples))
+	}
+	return Result{MonitorID: m.ID, Status: "up", Latency: latency, Message: msg, Data: marshalData(data)}
+}
+
+// parsePromResult decodes scalar and instant vector results. Range vectors
+// (matrix) cannot be compared against a threshold and are rejected.
+func parsePromResult(resultType string, raw json.RawMessage) ([]promSample, error) {
+	switch resultType {
+	case "scalar":
+		var pair []interface{}
+		if err := json.Unmarshal(raw, &pair); err != nil {
+			return nil, fmt.Errorf("invalid scalar result: %v", err)
+		}
+		v, err := promValue(pair)
+		if err != nil {
+			return nil, err
+		}
+		return []promSample{{Value: v}}, nil
+	case "vector":
+		var series []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []interface{}     `json:"value"`
+		}
+		if err := json.Unmarshal(raw, &series); err != nil {
+			return nil, fmt.Errorf("invalid vector result: %v", err)
+		}
+		samples := make([]promSample, 0, len(series))
+		for _, s := range series {
+			v, err := promValue(s.Value)
+			if err != nil {
+				return nil, err
+			}
+			samples = append(samples, promSample{Series: promSeriesName(s.Metric), Value: v})
+		}
+		sort.Slice(samples, func(i, j int) bool { return samples[i].Series < samples[j].Series })
+		return samples, nil
+	}
+	return nil, fmt.Errorf("unsupported result type %q, use an instant vector or scalar", resultType)
+}
+
+// promValue reads the value of a [timestamp, "value"] pair.
+func promValue(pair []interface{}) (float64, error) {
+	if len(pair) != 2 {
+		return 0, errors.New("invalid sample")
+	}
+	s, ok := pair[1].(string)
+	if !ok {
+		return 0, errors.New("invalid sample value")
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// promSeriesName renders labels like Prometheus does: name{label="value",...}.
+func promSeriesName(metric map[string]string) string {
+	name := metric["__name__"]
+	keys := make([]string, 0, len(metric))
+	for k := range metric {
+		if k != "__name__" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	labels := make([]string, len(keys))
+	for i, k := range keys {
+		labels[i] = fmt.Sprintf("%s=%q", k, metric[k])
+	}
+	if len(labels) == 0 {
+		return name
+	}
+	return name + "{" + strings.Join(labels, ",") + "}"
+}
+
+// jsonNumber keeps NaN and ±Inf, which JSON cannot represent, as strings.
+func jsonNumber(v float64) interface{} {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return v
+}
@@ -0,0 +1,126 @@
+package monitor
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParsePrometheusConfig(t *testing.T) {
+	cfg, err := parsePrometheusConfig(`{"query":"up{job=\"api\"}","min_value":1,"allow_empty":true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Query != `up{job="api"}` || cfg.MinValue == nil || *cfg.MinValue != 1 || cfg.MaxValue != nil || !cfg.AllowEmpty {
+		t.Errorf("parsePrometheusConfig = %+v", cfg)
+	}
+	for _, metadata := range []string{"", "{}", `{"query":"  "}`} {
+		if _, err := parsePrometheusConfig(metadata); err == nil {
+			t.Errorf("parsePrometheusConfig(%q): expected an error", metadata)
+		}
+	}
+}
+
+func TestParsePromResult(t *testing.T) {
+	tests := []struct {
+		resultType string
+		raw        string
+		want       []promSample
+	}{
+		{"scalar", `[1700000000.123, "42"]`, []promSample{{Value: 42}}},
+		{"vector", `[]`, []promSample{}},
+		{"vector", `[{"metric":{},"value":[1700000000,"0.5"]}]`, []promSample{{Value: 0.5}}},
+		{
+			"vector",
+			`[{"metric":{"__name__":"up","job":"web","instance":"b:80"},"value":[1700000000,"0"]},` +
+				`{"metric":{"__name__":"up","job":"api","instance":"a:80"},"value":[1700000000,"1"]}]`,
+			[]promSample{
+				{Series: `up{instance="a:80",job="api"}`, Value: 1},
+				{Series: `up{instance="b:80",job="web"}`, Value: 0},
+			},
+		},
+		{"vector", `[{"metric":{"job":"api"},"value":[1700000000,"+Inf"]}]`, []promSample{{Series: `{job="api"}`, Value: math.Inf(1)}}},
+	}
+	for _, tt := range tests {
+		got, err := parsePromResult(tt.resultType, json.RawMessage(tt.raw))
+		if err != nil {
+			t.Errorf("parsePromResult(%s, %s): %v", tt.resultType, tt.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePromResult(%s, %s) = %v, want %v", tt.resultType, tt.raw, got, tt.want)
+		}
+	}
+
+	errTests := []struct {
+		resultType string
+		raw        string
+	}{
+		{"matrix", `[{"metric":{},"values":[[1700000000,"1"]]}]`},
+		{"string", `[1700000000, "hello"]`},
+		{"scalar", `{}`},
+		{"scalar", `[1700000000]`},
+		{"scalar", `[1700000000, 42]`},
+		{"vector", `[{"metric":{},"value":[1700000000,"abc"]}]`},
+	}
+	for _, tt := range errTests {
+		if _, err := parsePromResult(tt.resultType, json.RawMessage(tt.raw)); err == nil {
+			t.Errorf("parsePromResult(%s, %s): expected an error", tt.resultType, tt.raw)
+		}
+	}
+}
+
+func TestCheckPrometheus(t *testing.T) {
+	results := map[string]string{
+		"one":   `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"api"},"value":[1700000000,"0.95"]}]}}`,
+		"two":   `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"api"},"value":[1700000000,"3"]},{"metric":{"job":"web"},"value":[1700000000,"12"]}]}}`,
+		"empty": `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+		"nan":   `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"NaN"]}}`,
+		"bad":   `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			http.NotFound(w, r)
+			return
+		}
+		body, ok := results[r.URL.Query().Get("query")]
+		if !ok {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	e := NewEngine(nil, nil)
+	defer e.Stop()
+
+	tests := []struct {
+		metadata string
+		status   string
+		message  string
+		data     string
+	}{
+		{`{"query":"one"}`, "up", "Value 0.95", `{"series":1,"value":0.95}`},
+		{`{"query":"one","min_value":0.99}`, "down", `{job="api"} 0.95 is below 0.99`, `{"series":1,"value":0.95}`},
+		{`{"query":"two","max_value":20}`, "up", "2 series within thresholds", `{"series":2,"values":{"{job=\"api\"}":3,"{job=\"web\"}":12}}`},
+		{`{"query":"two","max_value":10}`, "down", `{job="web"} 12 is above 10`, `{"series":2,"values":{"{job=\"api\"}":3,"{job=\"web\"}":12}}`},
+		{`{"query":"empty"}`, "down", "Query returned no data", `{"series":0}`},
+		{`{"query":"empty","allow_empty":true}`, "up", "Query returned no data", `{"series":0}`},
+		{`{"query":"nan"}`, "up", "Value NaN", `{"series":1,"value":"NaN"}`},
+		{`{"query":"nan","max_value":1}`, "down", "Value is NaN", `{"series":1,"value":"NaN"}`},
+		{`{"query":"bad"}`, "down", "Query failed: bad_data: parse error", ""},
+		{`{"query":"missing"}`, "down", "Invalid response (500 Internal Server Error)", ""},
+		{`{}`, "down", "prometheus monitor requires a query", ""},
+	}
+	for _, tt := range tests {
+		r := e.checkPrometheus(Monitor{ID: "m1", Target: srv.URL + "/", Metadata: tt.metadata})
+		if r.Status != tt.status || r.Message != tt.message || r.Data != tt.data {
+			t.Errorf("checkPrometheus(%s) = %s %q %s, want %s %q %s", tt.metadata,
+				r.Status, r.Message, r.Data, tt.status, tt.message, tt.data)
+		}
+	}
+}
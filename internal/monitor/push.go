@@ -3,17 +3,103 @@ package monitor
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/robfig/cron/v3"
 )
 
+// Push lifecycle signals
+const (
+	pushSignalPing  = "ping"
+	pushSignalStart = "start"
+	pushSignalFail  = "fail"
+)
+
+// maxPushLogBytes is how much of a job's captured output is kept per heartbeat.
+const maxPushLogBytes = 8 * 1024
+
+// defaultPushGrace is the slack allowed after the expected interval before a
+// push monitor is considered down.
+const defaultPushGrace = 5 * time.Second
+
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// pushConfig is the push-specific part of the monitor metadata.
+type pushConfig struct {
+	PushToken   string `json:"push_token"`
+	Cron        string `json:"cron"`         // e.g. "0 2 * * 1-5"
+	Timezone    string `json:"timezone"`     // e.g. "Asia/Shanghai", defaults to UTC
+	GracePeriod int    `json:"grace_period"` // seconds
+	MaxRuntime  int    `json:"max_runtime"`  // seconds a started run may take
+
+	// Email heartbeats (SMTP ingress)
+	EmailUpRegex   string `json:"email_up_regex"`
+	EmailDownRegex string `json:"email_down_regex"`
+}
+
+func parsePushConfig(metadata string) pushConfig {
+	var cfg pushConfig
+	json.Unmarshal([]byte(metadata), &cfg)
+	return cfg
+}
+
+func (cfg pushConfig) grace() time.Duration {
+	if cfg.GracePeriod > 0 {
+		return time.Duration(cfg.GracePeriod) * time.Second
+	}
+	return defaultPushGrace
+}
+
+// schedule returns the parsed cron schedule and its location, or nil when the
+// monitor uses a fixed interval.
+func (cfg pushConfig) schedule() (cron.Schedule, *time.Location, error) {
+	if cfg.Cron == "" {
+		return nil, nil, nil
+	}
+	loc := time.UTC
+	if cfg.Timezone != "" {
+		l, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid timezone %q: %v", cfg.Timezone, err)
+		}
+		loc = l
+	}
+	sched, err := cronParser.Parse(cfg.Cron)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid cron expression %q: %v", cfg.Cron, err)
+	}
+	return sched, loc, nil
+}
+
 func (e *Engine) RegisterPushRoutes(api *echo.Group) {
 	api.GET("/push/:id", e.handlePush)
 	api.POST("/push/:id", e.handlePush)
+	api.GET("/push/:id/finish", e.handlePush)
+	api.POST("/push/:id/finish", e.handlePush)
+	api.GET("/push/:id/start", e.handlePushStart)
+	api.POST("/push/:id/start", e.handlePushStart)
+	api.GET("/push/:id/fail", e.handlePushFail)
+	api.POST("/push/:id/fail", e.handlePushFail)
 }
 
 func (e *Engine) handlePush(c echo.Context) error {
+	return e.receivePush(c, pushSignalPing)
+}
+
+func (e *Engine) handlePushStart(c echo.Context) error {
+	return e.receivePush(c, pushSignalStart)
+}
+
+func (e *Engine) handlePushFail(c echo.Context) error {
+	return e.receivePush(c, pushSignalFail)
+}
+
+func (e *Engine) receivePush(c echo.Context, signal string) error {
 	id := c.Param("id")
 
 	// Get monitor to check for token in metadata
@@ -24,12 +110,10 @@ func (e *Engine) handlePush(c echo.Context) error {
 	}
 
 	// Check pushing auth if token is set in metadata
-	var metadata struct {
-		PushToken string `json:"push_token"`
-	}
-	if err := json.Unmarshal([]byte(m.Metadata), &metadata); err == nil && metadata.PushToken != "" {
+	cfg := parsePushConfig(m.Metadata)
+	if cfg.PushToken != "" {
 		authHeader := c.Request().Header.Get("Authorization")
-		expectedHeader := "Bearer " + metadata.PushToken
+		expectedHeader := "Bearer " + cfg.PushToken
 		if authHeader != expectedHeader {
 			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or missing push token"})
 		}
@@ -42,7 +126,14 @@ func (e *Engine) handlePush(c echo.Context) error {
 	data := make(map[string]interface{})
 
 	// Parse JSON body if Content-Type is application/json
-	if c.Request().Header.Get("Content-Type") == "application/json" {
+	contentType := c.Request().Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "text/plain") {
+		// Plain text body is the captured output of a wrapped job
+		body, _ := io.ReadAll(io.LimitReader(c.Request().Body, 1<<20))
+		if len(body) > 0 {
+			data["log"] = logTail(string(body))
+		}
+	} else if contentType == "application/json" {
 		var bodyData map[string]interface{}
 		if err := json.NewDecoder(c.Request().Body).Decode(&bodyData); err == nil {
 			for k, v := range bodyData {
@@ -64,10 +155,6 @@ func (e *Engine) handlePush(c echo.Context) error {
 		}
 	}
 
-	if status == "" {
-		status = "up"
-	}
-
 	params := c.QueryParams()
 	for k, v := range params {
 		if k == "status" || k == "msg" {
@@ -78,27 +165,259 @@ func (e *Engine) handlePush(c echo.Context) error {
 		}
 	}
 
-	dataJSON, _ := json.Marshal(data)
+	if err := e.recordPush(m, signal, status, msg, data); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
 
-	// Extract latency from 'ping' query parameter if available
-	var latency int
-	if p := c.QueryParam("ping"); p != "" {
+	return c.String(http.StatusOK, "OK")
+}
+
+// recordPush turns an authenticated push (HTTP, UDP, ...) into a heartbeat or
+// a run lifecycle event. data holds every reported field except status and msg.
+func (e *Engine) recordPush(m Monitor, signal, status, msg string, data map[string]interface{}) error {
+	if signal == pushSignalStart {
+		return e.startPushRun(m.ID)
+	}
+	if err := e.recordPushPing(m.ID); err != nil {
+		return err
+	}
+
+	if l, ok := data["log"].(string); ok {
+		data["log"] = logTail(l)
+	}
+
+	// A non-zero exit code marks the run as failed
+	exitCode, hasExitCode := 0, false
+	switch v := data["exit_code"].(type) {
+	case string:
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			exitCode, hasExitCode = n, true
+		}
+	case float64:
+		exitCode, hasExitCode = int(v), true
+	}
+	if hasExitCode {
+		data["exit_code"] = exitCode
+		if exitCode != 0 {
+			signal = pushSignalFail
+		}
+	}
+
+	if signal == pushSignalFail {
+		status = "down"
+		if msg == "" {
+			if hasExitCode {
+				msg = fmt.Sprintf("Job failed with exit code %d", exitCode)
+			} else {
+				msg = "Job failed"
+			}
+		}
+	}
+
+	if status == "" {
+		status = "up"
+	}
+
+	// Extract latency from the 'ping' field if available
+	latency := -1
+	switch p := data["ping"].(type) {
+	case string:
 		fmt.Sscanf(p, "%d", &latency)
-	} else if l, ok := data["ping"].(float64); ok {
-		// Also check JSON for ping/latency (often numeric in JSON)
-		latency = int(l)
+	case float64:
+		// JSON bodies carry ping/latency as a number
+		latency = int(p)
+	}
+
+	// A finished run reports its duration as latency
+	if started, ok := e.finishPushRun(m.ID); ok {
+		duration := time.Since(started)
+		data["run_started"] = started.Format(time.RFC3339)
+		data["duration_ms"] = duration.Milliseconds()
+		if latency < 0 {
+			latency = int(duration.Milliseconds())
+		}
 	}
+	if latency < 0 {
+		latency = 0
+	}
+
+	dataJSON, _ := json.Marshal(data)
 
 	// Save the heartbeat
 	res := Result{
-		MonitorID: id,
+		MonitorID: m.ID,
 		Status:    status,
 		Message:   msg,
 		Data:      string(dataJSON),
 		Latency:   latency,
 	}
 
+	e.applyRules(m, &res)
 	e.saveResult(res)
+	return nil
+}
 
-	return c.String(http.StatusOK, "OK")
+// startPushRun records that a job run has started.
+func (e *Engine) startPushRun(monitorID string) error {
+	_, err := e.db.Exec(`
+		INSERT INTO push_runs (monitor_id, started_at) VALUES (?, ?)
+		ON CONFLICT(monitor_id) DO UPDATE SET started_at = excluded.started_at
+	`, monitorID, time.Now().UTC())
+	return err
+}
+
+// finishPushRun clears the running job and returns when it started.
+func (e *Engine) finishPushRun(monitorID string) (time.Time, bool) {
+	var started time.Time
+	if err := e.db.Get(&started, "SELECT started_at FROM push_runs WHERE monitor_id = ? AND started_at IS NOT NULL", monitorID); err != nil {
+		return time.Time{}, false
+	}
+	e.db.Exec("UPDATE push_runs SET started_at = NULL WHERE monitor_id = ?", monitorID)
+	return started, true
+}
+
+// recordPushPing records when the job last pinged. The timeout checks read it
+// instead of the heartbeats, which also hold the timeout's own results.
+func (e *Engine) recordPushPing(monitorID string) error {
+	_, err := e.db.Exec(`
+		INSERT INTO push_runs (monitor_id, last_ping) VALUES (?, ?)
+		ON CONFLICT(monitor_id) DO UPDATE SET last_ping = excluded.last_ping
+	`, monitorID, time.Now().UTC())
+	return err
+}
+
+func (e *Engine) checkPushTimeout(m Monitor) {
+	cfg := parsePushConfig(m.Metadata)
+	now := time.Now().UTC()
+
+	var run struct {
+		StartedAt *time.Time `db:"started_at"`
+		LastPing  *time.Time `db:"last_ping"`
+	}
+	e.db.Get(&run, "SELECT started_at, last_ping FROM push_runs WHERE monitor_id = ?", m.ID)
+
+	var lastTime, runStarted time.Time
+	hasPing := run.LastPing != nil
+	if hasPing {
+		lastTime = *run.LastPing
+	}
+	running := run.StartedAt != nil
+	if running {
+		runStarted = *run.StartedAt
+	}
+
+	e.mu.RLock()
+	currentStatus := e.status[m.ID]
+	e.mu.RUnlock()
+
+	// Runaway job: started but never finished within the allowed runtime
+	if running && cfg.MaxRuntime > 0 {
+		maxRuntime := time.Duration(cfg.MaxRuntime) * time.Second
+		if elapsed := now.Sub(runStarted); elapsed > maxRuntime {
+			if currentStatus != "down" {
+				e.saveResult(Result{
+					MonitorID: m.ID,
+					Status:    "down",
+					Message:   fmt.Sprintf("Job running for %s, exceeds max runtime of %s", elapsed.Round(time.Second), maxRuntime),
+					Latency:   int(elapsed.Milliseconds()),
+					Data:      marshalData(map[string]interface{}{"runaway": true, "run_started": runStarted.Format(time.RFC3339)}),
+				})
+			}
+			return
+		}
+	}
+
+	sched, loc, err := cfg.schedule()
+	if err != nil {
+		return
+	}
+
+	if sched == nil {
+		isTimeout := !hasPing || now.Sub(lastTime) > time.Duration(m.Interval)*time.Second+cfg.grace()
+		// A run within its max runtime is still alive even if it has not pinged
+		if isTimeout && !(running && cfg.MaxRuntime > 0) && currentStatus != "down" {
+			e.saveResult(Result{
+				MonitorID: m.ID,
+				Status:    "down",
+				Message:   "Heartbeat timeout",
+				Latency:   0,
+			})
+		}
+		return
+	}
+
+	// Cron schedule: the next run after the last sign of life is the one we expect
+	lastSeen := lastTime
+	if running && runStarted.After(lastSeen) {
+		lastSeen = runStarted
+	}
+	if lastSeen.IsZero() {
+		lastSeen = e.firstSeen(m.ID, now)
+	}
+
+	status, due := cronStatus(sched, loc, lastSeen, now, cfg.grace())
+	if status == "" {
+		return
+	}
+
+	missed := due.Format(time.RFC3339)
+	data := marshalData(map[string]interface{}{"missed_run": missed})
+
+	if status == "down" {
+		if currentStatus != "down" {
+			e.saveResult(Result{
+				MonitorID: m.ID,
+				Status:    "down",
+				Message:   fmt.Sprintf("Missed scheduled run at %s", missed),
+				Data:      data,
+			})
+		}
+	} else if currentStatus != "late" && currentStatus != "down" {
+		e.saveResult(Result{
+			MonitorID: m.ID,
+			Status:    "late",
+			Message:   fmt.Sprintf("Scheduled run at %s is late", missed),
+			Data:      data,
+		})
+	}
+}
+
+// cronStatus compares now against the first run scheduled after lastSeen: it
+// returns "" while that run is not due, "late" within the grace period and
+// "down" after it, along with the time the run was due.
+func cronStatus(sched cron.Schedule, loc *time.Location, lastSeen, now time.Time, grace time.Duration) (string, time.Time) {
+	due := sched.Next(lastSeen.In(loc))
+	switch {
+	case !now.After(due):
+		return "", due
+	case now.After(due.Add(grace)):
+		return "down", due
+	}
+	return "late", due
+}
+
+// firstSeen returns when the engine first observed a monitor that has never
+// pushed, so a cron schedule has a starting point.
+func (e *Engine) firstSeen(monitorID string, now time.Time) time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	t, ok := e.lastChecks[monitorID]
+	if !ok {
+		t = now
+		e.lastChecks[monitorID] = t
+	}
+	return t
+}
+
+// logTail keeps the end of a job's output, which is where errors usually are.
+func logTail(s string) string {
+	if len(s) <= maxPushLogBytes {
+		return s
+	}
+	return strings.ToValidUTF8(s[len(s)-maxPushLogBytes:], "")
+}
+
+func marshalData(data map[string]interface{}) string {
+	b, _ := json.Marshal(data)
+	return string(b)
 }
@@ -0,0 +1,313 @@
+package monitor
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxMailSize caps the size of a received heartbeat email.
+const maxMailSize = 1 << 20
+
+// emailRegexes compiles the regexes mapping received emails to up or down.
+func (cfg pushConfig) emailRegexes() (up, down *regexp.Regexp, err error) {
+	if cfg.EmailUpRegex != "" {
+		if up, err = regexp.Compile(cfg.EmailUpRegex); err != nil {
+			return nil, nil, fmt.Errorf("invalid email_up_regex: %v", err)
+		}
+	}
+	if cfg.EmailDownRegex != "" {
+		if down, err = regexp.Compile(cfg.EmailDownRegex); err != nil {
+			return nil, nil, fmt.Errorf("invalid email_down_regex: %v", err)
+		}
+	}
+	return up, down, nil
+}
+
+// SMTPReceiver accepts heartbeat emails for push monitors. Each push monitor is
+// addressed as <monitor-id>+<push_token>@<domain>; sender addresses are trivial
+// to forge, so monitors without a push token cannot receive mail.
+type SMTPReceiver struct {
+	engine *Engine
+	domain string
+}
+
+func (e *Engine) NewSMTPReceiver(domain string) *SMTPReceiver {
+	return &SMTPReceiver{engine: e, domain: strings.ToLower(domain)}
+}
+
+// ListenAndServe accepts SMTP connections until the engine stops.
+func (s *SMTPReceiver) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-s.engine.ctx.Done()
+		ln.Close()
+	}()
+
+	log.Printf("SMTP push listener on %s", ln.Addr())
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if s.engine.ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("SMTP accept error: %v", err)
+			continue
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *SMTPReceiver) hostname() string {
+	if s.domain != "" {
+		return s.domain
+	}
+	return "aeromonitor"
+}
+
+func (s *SMTPReceiver) serveConn(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+
+	reply := func(format string, args ...interface{}) {
+		tp.PrintfLine(format, args...)
+	}
+
+	conn.SetDeadline(time.Now().Add(5 * time.Minute))
+	reply("220 %s AeroMonitor ESMTP ready", s.hostname())
+
+	var from string
+	var inMail bool // Between MAIL and the end of DATA
+	var recipients []Monitor
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			reply("250 %s", s.hostname())
+		case "EHLO":
+			reply("250-%s", s.hostname())
+			reply("250 SIZE %d", maxMailSize)
+		case "MAIL":
+			from = extractAddress(arg)
+			inMail = true
+			recipients = nil
+			reply("250 OK")
+		case "RCPT":
+			if !inMail {
+				reply("503 Need MAIL before RCPT")
+				continue
+			}
+			m, err := s.resolveRecipient(extractAddress(arg))
+			if err != nil {
+				reply("550 %v", err)
+				continue
+			}
+			recipients = append(recipients, m)
+			reply("250 OK")
+		case "DATA":
+			if len(recipients) == 0 {
+				reply("503 No valid recipients")
+				continue
+			}
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			dr := tp.DotReader()
+			raw, err := io.ReadAll(io.LimitReader(dr, maxMailSize+1))
+			if err != nil {
+				return
+			}
+			inMail = false
+			if len(raw) > maxMailSize {
+				// Read up to the final dot, or the rest would be taken as commands
+				if _, err := io.Copy(io.Discard, dr); err != nil {
+					return
+				}
+				recipients = nil
+				reply("552 Message too large")
+				continue
+			}
+			for _, m := range recipients {
+				if err := s.deliver(m, from, string(raw)); err != nil {
+					log.Printf("SMTP heartbeat for monitor %s failed: %v", m.ID, err)
+				}
+			}
+			recipients = nil
+			reply("250 OK")
+		case "RSET":
+			from, inMail, recipients = "", false, nil
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// extractAddress pulls the address out of "FROM:<a@b>" / "TO:<a@b> SIZE=..." arguments.
+func extractAddress(arg string) string {
+	if _, v, ok := strings.Cut(arg, ":"); ok {
+		arg = v
+	}
+	arg = strings.TrimSpace(arg)
+	if start := strings.Index(arg, "<"); start >= 0 {
+		if end := strings.Index(arg[start:], ">"); end > 0 {
+			return arg[start+1 : start+end]
+		}
+	}
+	addr, _, _ := strings.Cut(arg, " ")
+	return addr
+}
+
+func (s *SMTPReceiver) resolveRecipient(addr string) (Monitor, error) {
+	var m Monitor
+	local, domain, ok := strings.Cut(strings.ToLower(addr), "@")
+	if !ok {
+		return m, fmt.Errorf("invalid recipient %q", addr)
+	}
+	if s.domain != "" && domain != s.domain {
+		return m, fmt.Errorf("relay not permitted for %s", domain)
+	}
+
+	id, token, _ := strings.Cut(local, "+")
+	if err := s.engine.db.Get(&m, "SELECT * FROM monitors WHERE id = ?", id); err != nil || m.Type != TypePush {
+		return m, fmt.Errorf("no push monitor %s", id)
+	}
+
+	cfg := parsePushConfig(m.Metadata)
+	if cfg.PushToken == "" {
+		return m, fmt.Errorf("push monitor %s has no push token", id)
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(strings.ToLower(cfg.PushToken))) != 1 {
+		return m, fmt.Errorf("invalid push token for %s", id)
+	}
+	return m, nil
+}
+
+// deliver maps a received email onto the monitor's up/down regexes and records
+// it as a push heartbeat.
+func (s *SMTPReceiver) deliver(m Monitor, from, raw string) error {
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		return err
+	}
+
+	dec := new(mime.WordDecoder)
+	subject, err := dec.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+	body := readMailBody(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+
+	up, down, err := parsePushConfig(m.Metadata).emailRegexes()
+	if err != nil {
+		return err
+	}
+
+	content := subject + "\n" + body
+	data := map[string]interface{}{
+		"from":    from,
+		"subject": subject,
+	}
+
+	status := "up"
+	message := subject
+	if down != nil && down.MatchString(content) {
+		status = "down"
+		collectGroups(down, content, data)
+	} else if up != nil {
+		if up.MatchString(content) {
+			collectGroups(up, content, data)
+		} else {
+			status = "down"
+			message = "Email did not match expected content: " + subject
+		}
+	}
+
+	return s.engine.recordPush(m, pushSignalPing, status, message, data)
+}
+
+// collectGroups copies named capture groups of re into data.
+func collectGroups(re *regexp.Regexp, content string, data map[string]interface{}) {
+	match := re.FindStringSubmatch(content)
+	for i, name := range re.SubexpNames() {
+		if i > 0 && name != "" && i < len(match) {
+			data[name] = match[i]
+		}
+	}
+}
+
+// readMailBody returns the text of a message, preferring the text/plain part
+// of multipart messages.
+func readMailBody(contentType, encoding string, r io.Reader) string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err == nil && strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(r, params["boundary"])
+		var fallback string
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			text := readMailBody(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if strings.HasPrefix(part.Header.Get("Content-Type"), "text/plain") || part.Header.Get("Content-Type") == "" {
+				return text
+			}
+			if fallback == "" {
+				fallback = text
+			}
+		}
+		return fallback
+	}
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, &newlineStripper{r: bufio.NewReader(r)})
+	}
+	b, _ := io.ReadAll(io.LimitReader(r, maxMailSize))
+	return string(b)
+}
+
+// newlineStripper drops line breaks so wrapped base64 can be decoded.
+type newlineStripper struct {
+	r *bufio.Reader
+}
+
+func (n *newlineStripper) Read(p []byte) (int, error) {
+	i := 0
+	for i < len(p) {
+		b, err := n.r.ReadByte()
+		if err != nil {
+			return i, err
+		}
+		if b == '\r' || b == '\n' {
+			continue
+		}
+		p[i] = b
+		i++
+	}
+	return i, nil
+}
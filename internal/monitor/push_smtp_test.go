@@ -0,0 +1,132 @@
+package monitor
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"aeromonitor/internal/db"
+)
+
+func TestExtractAddress(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{"FROM:<backup@nas.local>", "backup@nas.local"},
+		{"FROM: <backup@nas.local> SIZE=1024", "backup@nas.local"},
+		{"TO:<m1+secret@push.example.com>", "m1+secret@push.example.com"},
+		{`TO:"Backup Job" <m1+secret@push.example.com>`, "m1+secret@push.example.com"},
+		{"FROM:<>", ""},
+		{"TO:m1+secret@push.example.com SIZE=10", "m1+secret@push.example.com"},
+		{"m1@push.example.com", "m1@push.example.com"},
+	}
+	for _, tt := range tests {
+		if got := extractAddress(tt.arg); got != tt.want {
+			t.Errorf("extractAddress(%q) = %q, want %q", tt.arg, got, tt.want)
+		}
+	}
+}
+
+func TestReadMailBody(t *testing.T) {
+	multipartAlt := "--b1\r\nContent-Type: text/html\r\n\r\n<p>Backup <b>failed</b></p>\r\n" +
+		"--b1\r\nContent-Type: text/plain; charset=utf-8\r\n\r\nBackup failed\r\n--b1--\r\n"
+	nested := "--outer\r\nContent-Type: multipart/alternative; boundary=b1\r\n\r\n" + multipartAlt +
+		"--outer\r\nContent-Type: application/pdf\r\nContent-Transfer-Encoding: base64\r\n\r\nJVBERi0=\r\n--outer--\r\n"
+
+	tests := []struct {
+		name        string
+		contentType string
+		encoding    string
+		body        string
+		want        string
+	}{
+		{"plain", "text/plain", "", "Backup completed\n", "Backup completed\n"},
+		{"no content type", "", "7bit", "Backup completed", "Backup completed"},
+		{"quoted-printable", "text/plain", "quoted-printable", "Sicherung erfolgreich =E2=9C=93 =\r\nfertig", "Sicherung erfolgreich ✓ fertig"},
+		{"base64 wrapped", "text/plain", "BASE64", "QmFja3VwIGNv\r\nbXBsZXRlZA==\r\n", "Backup completed"},
+		{"multipart prefers text/plain", "multipart/alternative; boundary=b1", "", multipartAlt, "Backup failed"},
+		{"multipart falls back to the first part", "multipart/alternative; boundary=b1",
+			"", "--b1\r\nContent-Type: text/html\r\n\r\n<p>only html</p>\r\n--b1--\r\n", "<p>only html</p>"},
+		{"nested multipart", "multipart/mixed; boundary=outer", "", nested, "Backup failed"},
+	}
+	for _, tt := range tests {
+		if got := readMailBody(tt.contentType, tt.encoding, strings.NewReader(tt.body)); got != tt.want {
+			t.Errorf("%s: readMailBody = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewlineStripper(t *testing.T) {
+	for _, size := range []int{1, 3, 64} {
+		r := &newlineStripper{r: bufio.NewReader(strings.NewReader("ab\r\ncd\n\nef\r\n"))}
+		var got []byte
+		buf := make([]byte, size)
+		for {
+			n, err := r.Read(buf)
+			got = append(got, buf[:n]...)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if string(got) != "abcdef" {
+			t.Errorf("buffer %d: read %q, want %q", size, got, "abcdef")
+		}
+	}
+}
+
+func TestSMTPSession(t *testing.T) {
+	d, err := db.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	e := NewEngine(d, nil)
+	defer e.Stop()
+	if _, err := d.Exec(`INSERT INTO monitors (id, owner_id, name, type, target, interval, notification_channels, metadata, monitor_group, paused)
+		VALUES ('m1', 'o', 'Backup', 'push', '', 60, '[]', '{"push_token":"Secret"}', '', 0),
+		       ('m2', 'o', 'Open', 'push', '', 60, '[]', '{}', '', 0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go e.NewSMTPReceiver("push.example.com").serveConn(server)
+	tp := textproto.NewConn(client)
+
+	expect := func(cmd string, code int) {
+		t.Helper()
+		if cmd != "" {
+			if err := tp.PrintfLine("%s", cmd); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, msg, err := tp.ReadResponse(code); err != nil {
+			t.Fatalf("%q: %v %s", cmd, err, msg)
+		}
+	}
+
+	expect("", 220)
+	expect("EHLO client", 250)
+	expect("RCPT TO:<m1+secret@push.example.com>", 503)
+	expect("MAIL FROM:<nas@example.com>", 250)
+	expect("RCPT TO:<m1+wrong@push.example.com>", 550)
+	expect("RCPT TO:<m2@push.example.com>", 550)
+	expect("RCPT TO:<m1+secret@elsewhere.com>", 550)
+	expect("DATA", 503)
+	expect("RCPT TO:<M1+SECRET@push.example.com>", 250)
+	expect("DATA", 354)
+	if err := tp.PrintfLine("Subject: Backup completed\r\n\r\nAll files saved.\r\n."); err != nil {
+		t.Fatal(err)
+	}
+	expect("", 250)
+	expect("RCPT TO:<m1+secret@push.example.com>", 503)
+	expect("QUIT", 221)
+}
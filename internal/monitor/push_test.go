@@ -0,0 +1,86 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPushSchedule(t *testing.T) {
+	for _, cfg := range []pushConfig{
+		{},
+		{Cron: "0 2 * * 1-5"},
+		{Cron: "@daily", Timezone: "Asia/Shanghai"},
+		{Cron: "*/15 * * * *", Timezone: "UTC"},
+	} {
+		if _, _, err := cfg.schedule(); err != nil {
+			t.Errorf("schedule(%q, %q): %v", cfg.Cron, cfg.Timezone, err)
+		}
+	}
+	for _, cfg := range []pushConfig{
+		{Cron: "0 2 * *"},
+		{Cron: "61 * * * *"},
+		{Cron: "0 0 * * * *"},
+		{Cron: "@sometimes"},
+		{Cron: "0 2 * * *", Timezone: "Mars/Olympus"},
+	} {
+		if _, _, err := cfg.schedule(); err == nil {
+			t.Errorf("schedule(%q, %q): expected an error", cfg.Cron, cfg.Timezone)
+		}
+	}
+}
+
+func TestCronStatus(t *testing.T) {
+	at := func(s string) time.Time {
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	grace := 10 * time.Minute
+
+	tests := []struct {
+		cron     string
+		timezone string
+		lastSeen string
+		now      string
+		status   string
+		due      string
+	}{
+		// Weekdays at 02:00 UTC; 2026-10-16 is a Friday
+		{"0 2 * * 1-5", "", "2026-10-15T02:00:30Z", "2026-10-15T12:00:00Z", "", "2026-10-16T02:00:00Z"},
+		{"0 2 * * 1-5", "", "2026-10-15T02:00:30Z", "2026-10-16T02:00:00Z", "", "2026-10-16T02:00:00Z"},
+		{"0 2 * * 1-5", "", "2026-10-15T02:00:30Z", "2026-10-16T02:05:00Z", "late", "2026-10-16T02:00:00Z"},
+		{"0 2 * * 1-5", "", "2026-10-15T02:00:30Z", "2026-10-16T02:10:01Z", "down", "2026-10-16T02:00:00Z"},
+		// The weekend is not expected
+		{"0 2 * * 1-5", "", "2026-10-16T02:00:30Z", "2026-10-18T23:00:00Z", "", "2026-10-19T02:00:00Z"},
+		// A ping for today's run means the next one is tomorrow
+		{"0 2 * * *", "", "2026-10-16T02:03:00Z", "2026-10-16T02:20:00Z", "", "2026-10-17T02:00:00Z"},
+		// 02:00 in Shanghai is 18:00 UTC the day before
+		{"0 2 * * *", "Asia/Shanghai", "2026-10-15T18:01:00Z", "2026-10-16T18:05:00Z", "late", "2026-10-16T18:00:00Z"},
+		{"0 2 * * *", "Asia/Shanghai", "2026-10-15T18:01:00Z", "2026-10-16T18:30:00Z", "down", "2026-10-16T18:00:00Z"},
+		// Europe/Berlin leaves summer time on 2026-10-25: 03:30 local moves from 01:30 to 02:30 UTC
+		{"30 3 * * *", "Europe/Berlin", "2026-10-24T01:31:00Z", "2026-10-25T01:45:00Z", "", "2026-10-25T02:30:00Z"},
+		{"30 3 * * *", "Europe/Berlin", "2026-10-24T01:31:00Z", "2026-10-25T02:35:00Z", "late", "2026-10-25T02:30:00Z"},
+	}
+	for _, tt := range tests {
+		sched, loc, err := pushConfig{Cron: tt.cron, Timezone: tt.timezone}.schedule()
+		if err != nil {
+			t.Fatal(err)
+		}
+		status, due := cronStatus(sched, loc, at(tt.lastSeen), at(tt.now), grace)
+		if status != tt.status || !due.Equal(at(tt.due)) {
+			t.Errorf("%q %s, last seen %s, now %s: %q due %s, want %q due %s", tt.cron, tt.timezone, tt.lastSeen, tt.now,
+				status, due.UTC().Format(time.RFC3339), tt.status, tt.due)
+		}
+	}
+}
+
+func TestPushGrace(t *testing.T) {
+	if got := (pushConfig{}).grace(); got != defaultPushGrace {
+		t.Errorf("default grace = %s, want %s", got, defaultPushGrace)
+	}
+	if got := (pushConfig{GracePeriod: 90}).grace(); got != 90*time.Second {
+		t.Errorf("grace = %s, want 1m30s", got)
+	}
+}
@@ -0,0 +1,209 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// UDP pushes are cheap to send and easy to spoof. Each source address is
+// limited before any lookup, so floods cost no database queries; each monitor
+// is limited to one datagram per second once its token has been verified, so
+// strangers cannot use up a device's allowance.
+const (
+	udpSourceRate  = 20
+	udpSourceBurst = 50
+	udpPushRate    = 1
+	udpPushBurst   = 5
+	udpLimiters    = 10000 // Limiters kept per kind
+)
+
+type udpLimiter struct {
+	mu       sync.Mutex
+	rate     rate.Limit
+	burst    int
+	limiters map[string]*rate.Limiter
+}
+
+func newUDPLimiter(r rate.Limit, burst int) *udpLimiter {
+	return &udpLimiter{rate: r, burst: burst, limiters: make(map[string]*rate.Limiter)}
+}
+
+func (l *udpLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lim, ok := l.limiters[key]
+	if !ok {
+		if len(l.limiters) >= udpLimiters {
+			// Forget the idle ones, whose bucket has refilled
+			for k, idle := range l.limiters {
+				if idle.Tokens() >= float64(l.burst) {
+					delete(l.limiters, k)
+				}
+			}
+			if len(l.limiters) >= udpLimiters {
+				return false
+			}
+		}
+		lim = rate.NewLimiter(l.rate, l.burst)
+		l.limiters[key] = lim
+	}
+	return lim.Allow()
+}
+
+// ServeUDP accepts push heartbeats over UDP, one per line:
+//
+//	<monitor-id> <token> status=up ping=12 msg="all good" key=value...
+//
+// A "signal=start", "signal=finish" or "signal=fail" field reports job
+// lifecycle like the /api/push/:id/start, /finish and /fail endpoints. It blocks until the engine stops.
+func (e *Engine) ServeUDP(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-e.ctx.Done()
+		conn.Close()
+	}()
+
+	log.Printf("UDP push listener on %s", conn.LocalAddr())
+	sources := newUDPLimiter(udpSourceRate, udpSourceBurst)
+	monitors := newUDPLimiter(udpPushRate, udpPushBurst)
+	buf := make([]byte, 64*1024)
+	for {
+		n, src, err := conn.ReadFrom(buf)
+		if err != nil {
+			if e.ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("UDP push read error: %v", err)
+			continue
+		}
+
+		host := src.String()
+		if addr, ok := src.(*net.UDPAddr); ok {
+			host = addr.IP.String()
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(buf[:n]))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			if !sources.allow(host) {
+				log.Printf("UDP push from %s rejected: rate limit exceeded", src)
+				break
+			}
+			if err := e.handleUDPLine(line, monitors); err != nil {
+				log.Printf("UDP push from %s rejected: %v", src, err)
+			}
+		}
+	}
+}
+
+// udpPush is one parsed UDP push line.
+type udpPush struct {
+	MonitorID string
+	Token     string
+	Signal    string
+	Status    string
+	Msg       string
+	Data      map[string]interface{}
+}
+
+func parseUDPLine(line string) (udpPush, error) {
+	fields, err := splitLineFields(line)
+	if err != nil {
+		return udpPush{}, err
+	}
+	if len(fields) < 2 {
+		return udpPush{}, errors.New("expected '<monitor-id> <token> key=value...'")
+	}
+	p := udpPush{MonitorID: fields[0], Token: fields[1], Signal: pushSignalPing, Data: make(map[string]interface{})}
+	for _, f := range fields[2:] {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok || k == "" {
+			return udpPush{}, fmt.Errorf("invalid field %q", f)
+		}
+		switch k {
+		case "status":
+			p.Status = v
+		case "msg":
+			p.Msg = v
+		case "signal":
+			switch v {
+			case pushSignalStart, pushSignalFail:
+				p.Signal = v
+			case "finish":
+				// A finished run is an ordinary ping
+				p.Signal = pushSignalPing
+			default:
+				return udpPush{}, fmt.Errorf("invalid signal %q", v)
+			}
+		default:
+			p.Data[k] = v
+		}
+	}
+	return p, nil
+}
+
+func (e *Engine) handleUDPLine(line string, limiter *udpLimiter) error {
+	p, err := parseUDPLine(line)
+	if err != nil {
+		return err
+	}
+
+	var m Monitor
+	if err := e.db.Get(&m, "SELECT * FROM monitors WHERE id = ?", p.MonitorID); err != nil || m.Type != TypePush {
+		return fmt.Errorf("unknown push monitor %q", p.MonitorID)
+	}
+
+	// UDP has no transport security, so a token is mandatory
+	cfg := parsePushConfig(m.Metadata)
+	if cfg.PushToken == "" || subtle.ConstantTimeCompare([]byte(p.Token), []byte(cfg.PushToken)) != 1 {
+		return fmt.Errorf("invalid push token for monitor %s", m.ID)
+	}
+
+	if !limiter.allow(m.ID) {
+		return fmt.Errorf("rate limit exceeded for monitor %s", m.ID)
+	}
+	return e.recordPush(m, p.Signal, p.Status, p.Msg, p.Data)
+}
+
+// splitLineFields splits a line on whitespace, keeping double-quoted values
+// (msg="disk almost full") together.
+func splitLineFields(line string) ([]string, error) {
+	var fields []string
+	var cur strings.Builder
+	inQuotes := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case (r == ' ' || r == '\t') && !inQuotes:
+			if cur.Len() > 0 {
+				fields = append(fields, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if inQuotes {
+		return nil, errors.New("unterminated quote")
+	}
+	if cur.Len() > 0 {
+		fields = append(fields, cur.String())
+	}
+	return fields, nil
+}
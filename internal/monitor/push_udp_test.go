@@ -0,0 +1,94 @@
+package monitor
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"aeromonitor/internal/db"
+)
+
+func TestParseUDPLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want udpPush
+	}{
+		{"m1 secret", udpPush{MonitorID: "m1", Token: "secret", Signal: pushSignalPing, Data: map[string]interface{}{}}},
+		{
+			`m1 secret status=down ping=12 msg="disk almost full" sats=18`,
+			udpPush{MonitorID: "m1", Token: "secret", Signal: pushSignalPing, Status: "down", Msg: "disk almost full",
+				Data: map[string]interface{}{"ping": "12", "sats": "18"}},
+		},
+		{"m1\tsecret  signal=start", udpPush{MonitorID: "m1", Token: "secret", Signal: pushSignalStart, Data: map[string]interface{}{}}},
+		{"m1 secret signal=finish", udpPush{MonitorID: "m1", Token: "secret", Signal: pushSignalPing, Data: map[string]interface{}{}}},
+		{"m1 secret signal=fail msg=", udpPush{MonitorID: "m1", Token: "secret", Signal: pushSignalFail, Data: map[string]interface{}{}}},
+		{`m1 secret note="a=b c"`, udpPush{MonitorID: "m1", Token: "secret", Signal: pushSignalPing, Data: map[string]interface{}{"note": "a=b c"}}},
+	}
+	for _, tt := range tests {
+		got, err := parseUDPLine(tt.line)
+		if err != nil {
+			t.Errorf("parseUDPLine(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseUDPLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{
+		"m1",
+		"m1 secret status",
+		"m1 secret =up",
+		"m1 secret signal=stop",
+		`m1 secret msg="unterminated`,
+	} {
+		if _, err := parseUDPLine(line); err == nil {
+			t.Errorf("parseUDPLine(%q): expected an error", line)
+		}
+	}
+}
+
+func TestUDPLimiter(t *testing.T) {
+	l := newUDPLimiter(1, 3)
+	for i := 0; i < 3; i++ {
+		if !l.allow("a") {
+			t.Fatalf("datagram %d of the burst was refused", i+1)
+		}
+	}
+	if l.allow("a") {
+		t.Error("datagram beyond the burst was allowed")
+	}
+	if !l.allow("b") {
+		t.Error("another key shares the exhausted bucket")
+	}
+}
+
+func TestHandleUDPLineLimitsAfterToken(t *testing.T) {
+	d, err := db.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	e := NewEngine(d, nil)
+	defer e.Stop()
+	if _, err := d.Exec(`INSERT INTO monitors (id, owner_id, name, type, target, interval, notification_channels, metadata, monitor_group, paused)
+		VALUES ('p1', 'o', 'Device', 'push', '', 60, '[]', '{"push_token":"secret"}', '', 0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	limiter := newUDPLimiter(udpPushRate, udpPushBurst)
+	for i := 0; i < 2*udpPushBurst; i++ {
+		if err := e.handleUDPLine("p1 guess status=down", limiter); err == nil {
+			t.Fatal("accepted a wrong token")
+		}
+		if err := e.handleUDPLine("made-up secret", limiter); err == nil {
+			t.Fatal("accepted an unknown monitor")
+		}
+	}
+	if len(limiter.limiters) != 0 {
+		t.Errorf("rejected pushes created %d limiters", len(limiter.limiters))
+	}
+	if err := e.handleUDPLine("p1 secret status=up", limiter); err != nil {
+		t.Errorf("valid push after rejected ones: %v", err)
+	}
+}
@@ -0,0 +1,445 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	rollupInterval = 5 * time.Minute
+	rollupGrace    = time.Minute // Heartbeats of a bucket may still arrive this long after it ends
+	purgeBatchSize = 5000
+)
+
+// rollupResolution is one of the aggregate tables.
+type rollupResolution struct {
+	table  string
+	period time.Duration
+}
+
+var (
+	hourlyRollups = rollupResolution{table: "heartbeat_rollups_hourly", period: time.Hour}
+	dailyRollups  = rollupResolution{table: "heartbeat_rollups_daily", period: 24 * time.Hour}
+)
+
+// Uptime counts degraded and late heartbeats as available: the service
+// answered, or the job has not missed its grace period yet. Maintenance
+// heartbeats are left out and anything else counts as down.
+const (
+	uptimeUpSQL    = "SUM(CASE WHEN status IN ('up', 'degraded', 'late') THEN 1 ELSE 0 END)"
+	uptimeTotalSQL = "SUM(CASE WHEN status <> 'maintenance' THEN 1 ELSE 0 END)"
+)
+
+// uptimePercent is the uptime for the counts of uptimeUpSQL and uptimeTotalSQL,
+// 100% without heartbeats.
+func uptimePercent(up, total int) float64 {
+	if total <= 0 {
+		return 100.0
+	}
+	return float64(up) / float64(total) * 100
+}
+
+// Rollup aggregates the heartbeats of a monitor over an hour or a day (UTC).
+// Late heartbeats are counted as degraded. Latency figures cover up and
+// degraded heartbeats only.
+type Rollup struct {
+	MonitorID        string    `db:"monitor_id" json:"monitor_id"`
+	Bucket           time.Time `db:"bucket" json:"bucket"`
+	TotalCount       int       `db:"total_count" json:"total_count"`
+	UpCount          int       `db:"up_count" json:"up_count"`
+	DownCount        int       `db:"down_count" json:"down_count"`
+	DegradedCount    int       `db:"degraded_count" json:"degraded_count"`
+	MaintenanceCount int       `db:"maintenance_count" json:"maintenance_count"`
+	LatencyMin       int       `db:"latency_min" json:"latency_min"`
+	LatencyAvg       float64   `db:"latency_avg" json:"latency_avg"`
+	LatencyP95       int       `db:"latency_p95" json:"latency_p95"`
+	LatencyMax       int       `db:"latency_max" json:"latency_max"`
+}
+
+// SetRetention makes the engine delete raw heartbeats older than d once they
+// are rolled up. Zero keeps them forever.
+func (e *Engine) SetRetention(d time.Duration) {
+	e.retention = d
+}
+
+// scheduleRollups starts a rollup and retention run every rollupInterval. It
+// runs in the background because the first run backfills the whole history.
+func (e *Engine) scheduleRollups() {
+	if e.forward != nil || time.Since(e.lastRollup) < rollupInterval {
+		return
+	}
+	if !e.rollupRunning.CompareAndSwap(false, true) {
+		return
+	}
+	e.lastRollup = time.Now()
+	go func() {
+		defer e.rollupRunning.Store(false)
+		e.rollupHeartbeats()
+		e.purgeHeartbeats()
+	}()
+}
+
+// rollupHeartbeats aggregates every completed bucket after the latest one in
+// each rollup table. Rewriting a bucket is harmless, so a run interrupted by
+// a leadership change is simply continued by the next leader.
+func (e *Engine) rollupHeartbeats() {
+	now := time.Now().UTC()
+	for _, r := range []rollupResolution{hourlyRollups, dailyRollups} {
+		next, err := e.rollupEnd(r)
+		if err == nil && next.IsZero() {
+			next, err = e.firstHeartbeatBucket(r)
+		}
+		if err != nil {
+			log.Printf("Error reading %s: %v", r.table, err)
+			continue
+		}
+		for !next.IsZero() && !next.Add(r.period+rollupGrace).After(now) {
+			if e.ctx.Err() != nil || !e.leading() {
+				return
+			}
+			if err := e.rollup(r, next); err != nil {
+				log.Printf("Error rolling up heartbeats into %s: %v", r.table, err)
+				break
+			}
+			next = next.Add(r.period)
+		}
+	}
+}
+
+// rollupEnd returns the end of the latest bucket in a rollup table, or the
+// zero time when it is empty.
+func (e *Engine) rollupEnd(r rollupResolution) (time.Time, error) {
+	var last []time.Time
+	if err := e.db.Select(&last, "SELECT bucket FROM "+r.table+" ORDER BY bucket DESC LIMIT 1"); err != nil {
+		return time.Time{}, err
+	}
+	if len(last) == 0 {
+		return time.Time{}, nil
+	}
+	return last[0].UTC().Add(r.period), nil
+}
+
+func (e *Engine) firstHeartbeatBucket(r rollupResolution) (time.Time, error) {
+	var first []time.Time
+	if err := e.db.Select(&first, "SELECT timestamp FROM heartbeats ORDER BY timestamp LIMIT 1"); err != nil {
+		return time.Time{}, err
+	}
+	if len(first) == 0 {
+		return time.Time{}, nil
+	}
+	return first[0].UTC().Truncate(r.period), nil
+}
+
+// rollup aggregates the raw heartbeats of one bucket for all monitors.
+func (e *Engine) rollup(r rollupResolution, bucket time.Time) error {
+	var beats []struct {
+		MonitorID string `db:"monitor_id"`
+		Status    string `db:"status"`
+		Latency   int    `db:"latency"`
+	}
+	err := e.db.Select(&beats, "SELECT monitor_id, status, COALESCE(latency, 0) as latency FROM heartbeats WHERE timestamp >= ? AND timestamp < ?",
+		bucket, bucket.Add(r.period))
+	if err != nil || len(beats) == 0 {
+		return err
+	}
+
+	rollups := make(map[string]*Rollup)
+	latencies := make(map[string][]int)
+	for _, b := range beats {
+		ru, ok := rollups[b.MonitorID]
+		if !ok {
+			ru = &Rollup{MonitorID: b.MonitorID, Bucket: bucket}
+			rollups[b.MonitorID] = ru
+		}
+		ru.TotalCount++
+		switch b.Status {
+		case "up":
+			ru.UpCount++
+			latencies[b.MonitorID] = append(latencies[b.MonitorID], b.Latency)
+		case "degraded":
+			ru.DegradedCount++
+			latencies[b.MonitorID] = append(latencies[b.MonitorID], b.Latency)
+		case "late":
+			ru.DegradedCount++
+		case "maintenance":
+			ru.MaintenanceCount++
+		default:
+			ru.DownCount++
+		}
+	}
+
+	tx, err := e.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for id, ru := range rollups {
+		summarizeLatency(ru, latencies[id])
+		_, err := tx.NamedExec(`
+			INSERT INTO `+r.table+` (monitor_id, bucket, total_count, up_count, down_count, degraded_count, maintenance_count, latency_min, latency_avg, latency_p95, latency_max)
+			VALUES (:monitor_id, :bucket, :total_count, :up_count, :down_count, :degraded_count, :maintenance_count, :latency_min, :latency_avg, :latency_p95, :latency_max)
+			ON CONFLICT(monitor_id, bucket) DO UPDATE SET
+				total_count = excluded.total_count, up_count = excluded.up_count, down_count = excluded.down_count,
+				degraded_count = excluded.degraded_count, maintenance_count = excluded.maintenance_count,
+				latency_min = excluded.latency_min, latency_avg = excluded.latency_avg,
+				latency_p95 = excluded.latency_p95, latency_max = excluded.latency_max
+		`, ru)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// summarizeLatency fills the latency figures, using the nearest-rank p95.
+func summarizeLatency(ru *Rollup, latencies []int) {
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Ints(latencies)
+	sum := 0
+	for _, l := range latencies {
+		sum += l
+	}
+	ru.LatencyMin = latencies[0]
+	ru.LatencyMax = latencies[len(latencies)-1]
+	ru.LatencyAvg = float64(sum) / float64(len(latencies))
+	ru.LatencyP95 = latencies[int(math.Ceil(0.95*float64(len(latencies))))-1]
+}
+
+// purgeHeartbeats deletes raw heartbeats older than the retention period.
+// Heartbeats not rolled up yet and the latest heartbeat of each monitor, which
+// holds its status and the state of stateful checks, are kept.
+func (e *Engine) purgeHeartbeats() {
+	if e.retention <= 0 {
+		return
+	}
+	cutoff := time.Now().UTC().Add(-e.retention)
+	for _, r := range []rollupResolution{hourlyRollups, dailyRollups} {
+		end, err := e.rollupEnd(r)
+		if err != nil {
+			log.Printf("Error reading %s: %v", r.table, err)
+			return
+		}
+		if end.Before(cutoff) {
+			cutoff = end
+		}
+	}
+
+	var monitorIDs []string
+	if err := e.db.Select(&monitorIDs, "SELECT id FROM monitors"); err != nil {
+		log.Printf("Error fetching monitors: %v", err)
+		return
+	}
+	keep := []int64{0}
+	for _, id := range monitorIDs {
+		var latest []int64
+		e.db.Select(&latest, "SELECT id FROM heartbeats WHERE monitor_id = ? ORDER BY timestamp DESC LIMIT 1", id)
+		keep = append(keep, latest...)
+	}
+	query, args, err := sqlx.In("DELETE FROM heartbeats WHERE id IN (SELECT id FROM heartbeats WHERE timestamp < ? AND id NOT IN (?) LIMIT ?)", cutoff, keep, purgeBatchSize)
+	if err != nil {
+		log.Printf("Error purging heartbeats: %v", err)
+		return
+	}
+	deleted := e.purgeBatches(query, args...)
+
+	// Location results are only needed for the current quorum
+	deleted += e.purgeBatches("DELETE FROM location_heartbeats WHERE id IN (SELECT id FROM location_heartbeats WHERE timestamp < ? LIMIT ?)",
+		time.Now().UTC().Add(-e.retention), purgeBatchSize)
+	if deleted > 0 {
+		log.Printf("Deleted %d heartbeats older than %s", deleted, cutoff.Format(time.RFC3339))
+	}
+}
+
+// purgeBatches repeats a batched delete until it removes less than a batch,
+// so other writers get the database in between.
+func (e *Engine) purgeBatches(query string, args ...interface{}) int64 {
+	var total int64
+	for e.ctx.Err() == nil && e.leading() {
+		res, err := e.db.Exec(query, args...)
+		if err != nil {
+			log.Printf("Error purging heartbeats: %v", err)
+			break
+		}
+		n, _ := res.RowsAffected()
+		total += n
+		if n < purgeBatchSize {
+			break
+		}
+	}
+	return total
+}
+
+// uptimeSince counts the heartbeats of a monitor since the start of the hour
+// of since. Whole days and hours come from the rollups, only the part not
+// rolled up yet from raw heartbeats.
+func (e *Engine) uptimeSince(monitorID string, since time.Time) (up, total int, err error) {
+	since = since.UTC().Truncate(time.Hour)
+	hourlyEnd, err := e.rollupEnd(hourlyRollups)
+	if err != nil {
+		return 0, 0, err
+	}
+	dailyEnd, err := e.rollupEnd(dailyRollups)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var counts struct {
+		Up    int `db:"up_count"`
+		Total int `db:"total_count"`
+	}
+	add := func(query string, args ...interface{}) error {
+		if err := e.db.Get(&counts, query, args...); err != nil {
+			return err
+		}
+		up += counts.Up
+		total += counts.Total
+		return nil
+	}
+	addRollups := func(r rollupResolution, from, to time.Time) error {
+		if !from.Before(to) {
+			return nil
+		}
+		return add("SELECT COALESCE(SUM(up_count + degraded_count), 0) as up_count, COALESCE(SUM(total_count - maintenance_count), 0) as total_count FROM "+r.table+" WHERE monitor_id = ? AND bucket >= ? AND bucket < ?",
+			monitorID, from, to)
+	}
+
+	rawFrom := since
+	if hourlyEnd.After(since) {
+		dayFrom := since.Add(dailyRollups.period - time.Nanosecond).Truncate(dailyRollups.period)
+		if dailyEnd.After(dayFrom) && !dailyEnd.After(hourlyEnd) {
+			if err := addRollups(dailyRollups, dayFrom, dailyEnd); err != nil {
+				return 0, 0, err
+			}
+			if err := addRollups(hourlyRollups, since, dayFrom); err != nil {
+				return 0, 0, err
+			}
+			if err := addRollups(hourlyRollups, dailyEnd, hourlyEnd); err != nil {
+				return 0, 0, err
+			}
+		} else if err := addRollups(hourlyRollups, since, hourlyEnd); err != nil {
+			return 0, 0, err
+		}
+		rawFrom = hourlyEnd
+	}
+	err = add(`
+		SELECT COALESCE(`+uptimeUpSQL+`, 0) as up_count, COALESCE(`+uptimeTotalSQL+`, 0) as total_count
+		FROM heartbeats WHERE monitor_id = ? AND timestamp >= ?
+	`, monitorID, rawFrom)
+	return up, total, err
+}
+
+// heartbeatRawWindow is how much recent history the heartbeat list serves as
+// raw heartbeats; older history comes from the hourly rollups.
+const heartbeatRawWindow = 24 * time.Hour
+
+// rollupPoints returns the hourly rollups of a monitor between since and until
+// as heartbeats, newest first. A point is down when most of the hour was down
+// and degraded when some of it was; its latency is the hour's average.
+func (e *Engine) rollupPoints(monitorID string, since, until time.Time) ([]Heartbeat, error) {
+	var rollups []Rollup
+	err := e.db.Select(&rollups, "SELECT * FROM "+hourlyRollups.table+" WHERE monitor_id = ? AND bucket >= ? AND bucket < ? ORDER BY bucket DESC",
+		monitorID, since.Truncate(time.Hour), until)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]Heartbeat, 0, len(rollups))
+	for _, ru := range rollups {
+		var status string
+		switch {
+		case ru.DownCount > ru.UpCount+ru.DegradedCount:
+			status = "down"
+		case ru.DownCount > 0 || ru.DegradedCount > 0:
+			status = "degraded"
+		case ru.UpCount > 0:
+			status = "up"
+		default:
+			status = "maintenance"
+		}
+		points = append(points, Heartbeat{
+			ID:        -ru.Bucket.Unix(), // Never clashes with a heartbeat id
+			MonitorID: monitorID,
+			Status:    status,
+			Latency:   int(math.Round(ru.LatencyAvg)),
+			Message:   fmt.Sprintf("Hourly summary: %d up, %d degraded, %d down", ru.UpCount, ru.DegradedCount, ru.DownCount),
+			Data:      `{"rollup":"hourly"}`,
+			Timestamp: ru.Bucket.UTC(),
+		})
+	}
+	return points, nil
+}
+
+// getUptime returns the uptime percentage of a monitor over 24 hours, 7, 30
+// and 90 days.
+func (e *Engine) getUptime(c echo.Context) error {
+	id := c.Param("id")
+	ranges := []struct {
+		name   string
+		period time.Duration
+	}{
+		{"24h", 24 * time.Hour},
+		{"7d", 7 * 24 * time.Hour},
+		{"30d", 30 * 24 * time.Hour},
+		{"90d", 90 * 24 * time.Hour},
+	}
+	now := time.Now().UTC()
+	uptime := make(map[string]float64, len(ranges))
+	for _, r := range ranges {
+		up, total, err := e.uptimeSince(id, now.Add(-r.period))
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		uptime[r.name] = uptimePercent(up, total)
+	}
+	return c.JSON(http.StatusOK, uptime)
+}
+
+// getRollups returns the hourly (default, last 7 days) or daily (last 90 days)
+// aggregates of a monitor, optionally limited by start and end.
+func (e *Engine) getRollups(c echo.Context) error {
+	id := c.Param("id")
+	r, span := hourlyRollups, 7*24*time.Hour
+	switch c.QueryParam("resolution") {
+	case "", "hourly":
+	case "daily":
+		r, span = dailyRollups, 90*24*time.Hour
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "resolution must be hourly or daily"})
+	}
+
+	end := time.Now().UTC()
+	if t, ok := parseTimeParam(c.QueryParam("end")); ok {
+		end = t
+	}
+	start := end.Add(-span)
+	if t, ok := parseTimeParam(c.QueryParam("start")); ok {
+		start = t
+	}
+
+	rollups := []Rollup{}
+	err := e.db.Select(&rollups, "SELECT * FROM "+r.table+" WHERE monitor_id = ? AND bucket >= ? AND bucket < ? ORDER BY bucket", id, start, end)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, rollups)
+}
+
+// parseTimeParam accepts the datetime-local format of the UI and RFC 3339.
+func parseTimeParam(s string) (time.Time, bool) {
+	if t, err := time.Parse("2006-01-02T15:04", s); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), true
+	}
+	return time.Time{}, false
+}
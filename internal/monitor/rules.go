@@ -0,0 +1,332 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rule severities
+const (
+	SeverityDown     = "down"
+	SeverityDegraded = "degraded"
+)
+
+// DataRule is a threshold rule evaluated against the custom data of a heartbeat.
+// Rules are configured in the monitor metadata:
+//
+//	"rules": [
+//	  {"expr": "temperature > 80", "severity": "degraded"},
+//	  {"expr": "queue_depth >= 1000 for 3 consecutive"},
+//	  {"expr": "state == \"ok\""},
+//	  {"expr": "disk_free_pct missing"}
+//	]
+type DataRule struct {
+	Expr     string `json:"expr"`
+	Severity string `json:"severity"` // "down" (default) or "degraded"
+	Message  string `json:"message"`
+
+	field       string
+	op          string
+	value       string
+	consecutive int
+}
+
+var ruleOperators = []string{">=", "<=", "==", "!=", ">", "<"}
+
+// Rule token kinds
+const (
+	tokenWord = iota
+	tokenQuoted
+	tokenOperator
+)
+
+type ruleToken struct {
+	kind int
+	text string
+}
+
+// tokenizeRule splits an expression into operators, quoted strings and words.
+// Operators end a word, so "temp>80" and "temp > 80" are the same rule, while
+// operators and spaces inside quotes belong to the value.
+func tokenizeRule(expr string) ([]ruleToken, error) {
+	var tokens []ruleToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			quoted, err := strconv.QuotedPrefix(expr[i:])
+			if err != nil {
+				return nil, errors.New("unterminated string")
+			}
+			text, _ := strconv.Unquote(quoted)
+			tokens = append(tokens, ruleToken{tokenQuoted, text})
+			i += len(quoted)
+		case c == '\'':
+			end := strings.IndexByte(expr[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated string")
+			}
+			tokens = append(tokens, ruleToken{tokenQuoted, expr[i+1 : i+1+end]})
+			i += end + 2
+		case strings.IndexByte("<>=!", c) >= 0:
+			op := ""
+			for _, candidate := range ruleOperators {
+				if strings.HasPrefix(expr[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("invalid operator at %q", expr[i:])
+			}
+			tokens = append(tokens, ruleToken{tokenOperator, op})
+			i += len(op)
+		default:
+			end := i
+			for end < len(expr) && strings.IndexByte(" \t<>=!\"'", expr[end]) < 0 {
+				end++
+			}
+			tokens = append(tokens, ruleToken{tokenWord, expr[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// parseRule parses expressions of the form
+// "<field> <op> <value> [for <n> [consecutive]]" or "<field> missing|exists".
+// Values containing spaces or operators are quoted: state == "in use".
+func parseRule(r DataRule) (DataRule, error) {
+	expr := strings.TrimSpace(r.Expr)
+	r.consecutive = 1
+
+	switch r.Severity {
+	case "":
+		r.Severity = SeverityDown
+	case SeverityDown, SeverityDegraded:
+	default:
+		return r, fmt.Errorf("rule %q: invalid severity %q", expr, r.Severity)
+	}
+
+	tokens, err := tokenizeRule(expr)
+	if err != nil {
+		return r, fmt.Errorf("rule %q: %v", expr, err)
+	}
+	syntaxErr := fmt.Errorf("rule %q: expected '<field> <op> <value>' or '<field> missing'", expr)
+	if len(tokens) < 2 || tokens[0].kind != tokenWord {
+		return r, syntaxErr
+	}
+	r.field = tokens[0].text
+
+	var rest []ruleToken
+	switch op := tokens[1]; {
+	case op.kind == tokenWord && (op.text == "missing" || op.text == "exists"):
+		r.op = op.text
+		rest = tokens[2:]
+	case op.kind == tokenOperator:
+		if len(tokens) < 3 || tokens[2].kind == tokenOperator {
+			return r, syntaxErr
+		}
+		r.op, r.value = op.text, tokens[2].text
+		rest = tokens[3:]
+	default:
+		return r, syntaxErr
+	}
+
+	// Optional "for N consecutive" suffix
+	if len(rest) == 0 {
+		return r, nil
+	}
+	forErr := fmt.Errorf("rule %q: invalid 'for' clause", expr)
+	if len(rest) < 2 || len(rest) > 3 || rest[0] != (ruleToken{tokenWord, "for"}) || rest[1].kind != tokenWord {
+		return r, forErr
+	}
+	if len(rest) == 3 && rest[2] != (ruleToken{tokenWord, "consecutive"}) {
+		return r, forErr
+	}
+	n, err := strconv.Atoi(rest[1].text)
+	if err != nil || n < 1 {
+		return r, forErr
+	}
+	r.consecutive = n
+	return r, nil
+}
+
+// parseRules reads and validates the rules from monitor metadata.
+func parseRules(metadata string) ([]DataRule, error) {
+	var meta struct {
+		Rules []DataRule `json:"rules"`
+	}
+	if metadata == "" {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(metadata), &meta); err != nil {
+		return nil, fmt.Errorf("invalid rules metadata: %v", err)
+	}
+
+	rules := make([]DataRule, 0, len(meta.Rules))
+	for _, r := range meta.Rules {
+		parsed, err := parseRule(r)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, parsed)
+	}
+	return rules, nil
+}
+
+// lookupField resolves a dotted path such as "disk.free_pct" in the data map.
+func lookupField(data map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = data
+	for _, part := range strings.Split(path, ".") {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = obj[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case bool:
+		if n {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// violated reports whether the rule is violated by data, along with the
+// observed value for the message.
+func (r DataRule) violated(data map[string]interface{}) (bool, string) {
+	v, ok := lookupField(data, r.field)
+	switch r.op {
+	case "missing":
+		return !ok, "missing"
+	case "exists":
+		return ok, "present"
+	}
+	if !ok {
+		// Comparisons against a missing field are not violations; use "missing" for that
+		return false, ""
+	}
+
+	observed := fmt.Sprintf("%v", v)
+	actual, actualNum := toFloat(v)
+	expected, expectedNum := toFloat(r.value)
+
+	if actualNum && expectedNum {
+		switch r.op {
+		case ">":
+			return actual > expected, observed
+		case ">=":
+			return actual >= expected, observed
+		case "<":
+			return actual < expected, observed
+		case "<=":
+			return actual <= expected, observed
+		case "==":
+			return actual == expected, observed
+		case "!=":
+			return actual != expected, observed
+		}
+	}
+
+	switch r.op {
+	case "==":
+		return observed == r.value, observed
+	case "!=":
+		return observed != r.value, observed
+	}
+	// Ordering comparison against a non-numeric value
+	return false, observed
+}
+
+// applyRules evaluates the monitor's data rules against a successful result and
+// downgrades it to "degraded" or "down" when a rule is violated.
+func (e *Engine) applyRules(m Monitor, res *Result) {
+	rules, err := parseRules(m.Metadata)
+	if err != nil || len(rules) == 0 {
+		return
+	}
+
+	data := make(map[string]interface{})
+	if res.Data != "" {
+		json.Unmarshal([]byte(res.Data), &data)
+	}
+
+	var messages []string
+	status := res.Status
+
+	e.mu.Lock()
+	// Streaks are kept per rule position and expression, so edited, added or
+	// removed rules start over, whichever instance saved the change
+	prev := e.ruleStreaks[m.ID]
+	streaks := make(map[string]int, len(rules))
+	e.ruleStreaks[m.ID] = streaks
+	for i, r := range rules {
+		key := fmt.Sprintf("%d\x00%s", i, r.Expr)
+		bad, observed := r.violated(data)
+		if !bad || res.Status != "up" {
+			continue
+		}
+		streaks[key] = prev[key] + 1
+		if streaks[key] < r.consecutive {
+			continue
+		}
+
+		msg := r.Message
+		if msg == "" {
+			msg = fmt.Sprintf("Rule violated: %s (value: %s)", strings.TrimSpace(r.Expr), observed)
+		}
+		messages = append(messages, msg)
+		if r.Severity == SeverityDown || status == "up" {
+			status = r.Severity
+		}
+	}
+	e.mu.Unlock()
+
+	if len(messages) > 0 {
+		res.Status = status
+		res.Message = strings.Join(messages, "; ")
+	}
+}
+
+// pruneRuleStreaks drops the streaks of monitors deleted on another instance.
+func (e *Engine) pruneRuleStreaks(monitors []Monitor) {
+	known := make(map[string]bool, len(monitors))
+	for _, m := range monitors {
+		known[m.ID] = true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for id := range e.ruleStreaks {
+		if !known[id] {
+			delete(e.ruleStreaks, id)
+		}
+	}
+}
+
+// resetRuleStreaks forgets the rule violations counted for a monitor.
+func (e *Engine) resetRuleStreaks(monitorID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.ruleStreaks, monitorID)
+}
@@ -0,0 +1,114 @@
+package monitor
+
+import "testing"
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		expr        string
+		field       string
+		op          string
+		value       string
+		consecutive int
+	}{
+		{"temperature > 80", "temperature", ">", "80", 1},
+		{"temperature>80", "temperature", ">", "80", 1},
+		{"queue_depth >= 1000 for 3 consecutive", "queue_depth", ">=", "1000", 3},
+		{"queue_depth >= 1000 for 2", "queue_depth", ">=", "1000", 2},
+		{`state == "ok"`, "state", "==", "ok", 1},
+		{`state == 'ok'`, "state", "==", "ok", 1},
+		{`msg == "ready for work"`, "msg", "==", "ready for work", 1},
+		{`expr != "a>=b"`, "expr", "!=", "a>=b", 1},
+		{`msg == "waiting for 5 consecutive"  for 2 consecutive`, "msg", "==", "waiting for 5 consecutive", 2},
+		{`disk.free_pct < -1.5`, "disk.free_pct", "<", "-1.5", 1},
+		{"disk_free_pct missing", "disk_free_pct", "missing", "", 1},
+		{"error exists for 2", "error", "exists", "", 2},
+	}
+	for _, tt := range tests {
+		r, err := parseRule(DataRule{Expr: tt.expr})
+		if err != nil {
+			t.Errorf("parseRule(%q): %v", tt.expr, err)
+			continue
+		}
+		if r.field != tt.field || r.op != tt.op || r.value != tt.value || r.consecutive != tt.consecutive {
+			t.Errorf("parseRule(%q) = %q %q %q x%d, want %q %q %q x%d", tt.expr,
+				r.field, r.op, r.value, r.consecutive, tt.field, tt.op, tt.value, tt.consecutive)
+		}
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"temperature",
+		"temperature >",
+		"> 80",
+		"temperature => 80",
+		"temperature > > 80",
+		"the temperature > 80",
+		`state == "ok`,
+		"temperature > 80 for",
+		"temperature > 80 for 0",
+		"temperature > 80 for x",
+		"temperature > 80 for 3 times",
+		"temperature > 80 during 3",
+	} {
+		if _, err := parseRule(DataRule{Expr: expr}); err == nil {
+			t.Errorf("parseRule(%q): expected an error", expr)
+		}
+	}
+	if _, err := parseRule(DataRule{Expr: "a > 1", Severity: "warning"}); err == nil {
+		t.Error("expected an error for an invalid severity")
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	for _, metadata := range []string{"", "{}", `{"rules": []}`, `{"other": 1}`} {
+		if rules, err := parseRules(metadata); err != nil || len(rules) != 0 {
+			t.Errorf("parseRules(%q) = %v, %v, want no rules", metadata, rules, err)
+		}
+	}
+	if rules, err := parseRules(`{"rules": [{"expr": "a > 1"}, {"expr": "b missing"}]}`); err != nil || len(rules) != 2 {
+		t.Errorf("parseRules = %v, %v, want 2 rules", rules, err)
+	}
+	for _, metadata := range []string{
+		`{"rules": [{"expr": "a > 1"}`,
+		`{"rules": {"expr": "a > 1"}}`,
+		`{"rules": ["a > 1"]}`,
+		`{"rules": [{"expr": "a >"}]}`,
+	} {
+		if _, err := parseRules(metadata); err == nil {
+			t.Errorf("parseRules(%q): expected an error", metadata)
+		}
+	}
+}
+
+func TestApplyRulesStreaks(t *testing.T) {
+	e := NewEngine(nil, nil)
+	m := Monitor{ID: "m1", Metadata: `{"rules": [{"expr": "temp > 80 for 2"}]}`}
+	apply := func() string {
+		res := Result{MonitorID: m.ID, Status: "up", Data: `{"temp": 90}`}
+		e.applyRules(m, &res)
+		return res.Status
+	}
+
+	if got := apply(); got != "up" {
+		t.Fatalf("first violation: got %s, want up", got)
+	}
+	if got := apply(); got != "down" {
+		t.Fatalf("second violation: got %s, want down", got)
+	}
+
+	// An edited rule starts over
+	m.Metadata = `{"rules": [{"expr": "temp > 85 for 2"}]}`
+	if got := apply(); got != "up" {
+		t.Fatalf("after edit: got %s, want up", got)
+	}
+
+	e.resetRuleStreaks(m.ID)
+	if got := apply(); got != "up" {
+		t.Fatalf("after reset: got %s, want up", got)
+	}
+	if got := apply(); got != "down" {
+		t.Fatalf("after reset, second violation: got %s, want down", got)
+	}
+}
@@ -0,0 +1,273 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	sshDefaultPort    = "22"
+	sshDefaultTimeout = 30 * time.Second
+	maxCommandOutput  = 64 * 1024
+)
+
+// Stdout formats of ssh (and exec) monitors
+const (
+	outputFormatRaw      = ""         // Stored as "stdout"
+	outputFormatJSON     = "json"     // A JSON object merged into the data
+	outputFormatKeyValue = "keyvalue" // One key=value per line
+)
+
+// sshConfig is the SSH-specific part of the monitor metadata. The target is
+// host[:port].
+type sshConfig struct {
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	PrivateKey string `json:"private_key"` // PEM
+	Passphrase string `json:"passphrase"`
+
+	// HostKeyFingerprint pins the server key, e.g. "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8"
+	// as printed by ssh-keygen -lf. Required unless InsecureIgnoreHostKey is set.
+	HostKeyFingerprint    string `json:"host_key_fingerprint"`
+	InsecureIgnoreHostKey bool   `json:"insecure_ignore_host_key"`
+
+	Command          string `json:"command"`
+	ExpectedExitCode int    `json:"expected_exit_code"`
+	ExpectRegex      string `json:"expect_regex"` // Matched against stdout
+	OutputFormat     string `json:"output_format"`
+	Timeout          int    `json:"timeout"` // seconds
+
+	// Assertions on the trimmed stdout (value) and the parsed fields
+	queryAssertion
+}
+
+func parseSSHConfig(metadata string) (sshConfig, error) {
+	var cfg sshConfig
+	json.Unmarshal([]byte(metadata), &cfg)
+	if cfg.Command == "" {
+		return cfg, errors.New("ssh monitor requires a command")
+	}
+	if cfg.Username == "" {
+		return cfg, errors.New("ssh monitor requires a username")
+	}
+	if cfg.Password == "" && cfg.PrivateKey == "" {
+		return cfg, errors.New("ssh monitor requires a password or private_key")
+	}
+	if cfg.HostKeyFingerprint == "" && !cfg.InsecureIgnoreHostKey {
+		return cfg, errors.New("ssh monitor requires host_key_fingerprint")
+	}
+	if _, err := cfg.authMethods(); err != nil {
+		return cfg, err
+	}
+	if err := validateOutputFormat(cfg.OutputFormat); err != nil {
+		return cfg, err
+	}
+	if cfg.ExpectRegex != "" {
+		if _, err := regexp.Compile(cfg.ExpectRegex); err != nil {
+			return cfg, fmt.Errorf("invalid expect_regex: %v", err)
+		}
+	}
+	return cfg, nil
+}
+
+func (cfg sshConfig) timeout() time.Duration {
+	if cfg.Timeout > 0 {
+		return time.Duration(cfg.Timeout) * time.Second
+	}
+	return sshDefaultTimeout
+}
+
+func (cfg sshConfig) authMethods() ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if cfg.PrivateKey != "" {
+		var signer ssh.Signer
+		var err error
+		if cfg.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(cfg.PrivateKey), []byte(cfg.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(cfg.PrivateKey))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid private_key: %v", err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		methods = append(methods, ssh.Password(cfg.Password))
+	}
+	return methods, nil
+}
+
+func (cfg sshConfig) hostKeyCallback() ssh.HostKeyCallback {
+	if cfg.HostKeyFingerprint == "" {
+		return ssh.InsecureIgnoreHostKey()
+	}
+	want := strings.TrimPrefix(strings.TrimSpace(cfg.HostKeyFingerprint), "SHA256:")
+	return func(_ string, _ net.Addr, key ssh.PublicKey) error {
+		got := strings.TrimPrefix(ssh.FingerprintSHA256(key), "SHA256:")
+		if got != want {
+			return fmt.Errorf("host key mismatch: got SHA256:%s", got)
+		}
+		return nil
+	}
+}
+
+func (e *Engine) checkSSH(m Monitor) Result {
+	cfg, err := parseSSHConfig(m.Metadata)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}
+	}
+	auth, _ := cfg.authMethods()
+
+	clientConfig := &ssh.ClientConfig{
+		User:            cfg.Username,
+		Auth:            auth,
+		HostKeyCallback: cfg.hostKeyCallback(),
+		Timeout:         cfg.timeout(),
+	}
+
+	start := time.Now()
+	deadline := start.Add(cfg.timeout())
+	addr := withDefaultPort(m.Target, sshDefaultPort)
+	conn, err := net.DialTimeout("tcp", addr, cfg.timeout())
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}
+	}
+	defer conn.Close()
+	// Bounds the handshake and the command as a whole
+	conn.SetDeadline(deadline)
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, clientConfig)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Latency: int(time.Since(start).Milliseconds()), Message: fmt.Sprintf("SSH login failed: %v", err)}
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+	connectMs := time.Since(start).Milliseconds()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Latency: int(connectMs), Message: fmt.Sprintf("SSH session failed: %v", err)}
+	}
+	defer session.Close()
+
+	var stdout, stderr limitedBuffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	start = time.Now()
+	exitCode := 0
+	if err := session.Run(cfg.Command); err != nil {
+		var exitErr *ssh.ExitError
+		if !errors.As(err, &exitErr) {
+			return Result{MonitorID: m.ID, Status: "down", Latency: int(time.Since(start).Milliseconds()), Message: fmt.Sprintf("Command failed: %v", err)}
+		}
+		exitCode = exitErr.ExitStatus()
+	}
+	latency := int(time.Since(start).Milliseconds())
+
+	data := map[string]interface{}{
+		"connect_ms": connectMs,
+		"exit_code":  exitCode,
+	}
+	output := strings.TrimSpace(stdout.String())
+	fields, err := parseCommandOutput(cfg.OutputFormat, output)
+	if err != nil {
+		data["stdout"] = logTail(output)
+		return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: fmt.Sprintf("Invalid command output: %v", err), Data: marshalData(data)}
+	}
+	// Parsed output is nested so a field named like a check field cannot replace it
+	if cfg.OutputFormat != outputFormatRaw {
+		data["output"] = fields
+	} else if output != "" {
+		data["stdout"] = logTail(output)
+	}
+
+	if exitCode != cfg.ExpectedExitCode {
+		msg := fmt.Sprintf("Exit code %d, expected %d", exitCode, cfg.ExpectedExitCode)
+		if s := firstLine(stderr.String()); s != "" {
+			msg += ": " + s
+		}
+		return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: msg, Data: marshalData(data)}
+	}
+	if cfg.ExpectRegex != "" && !regexp.MustCompile(cfg.ExpectRegex).MatchString(output) {
+		return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: "Output did not match expect_regex", Data: marshalData(data)}
+	}
+	if err := cfg.check(output, fields); err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Latency: latency, Message: fmt.Sprintf("Assertion failed: %v", err), Data: marshalData(data)}
+	}
+
+	msg := "Command succeeded"
+	if s := firstLine(output); s != "" && cfg.OutputFormat == outputFormatRaw {
+		msg = s
+	}
+	return Result{MonitorID: m.ID, Status: "up", Latency: latency, Message: msg, Data: marshalData(data)}
+}
+
+func validateOutputFormat(format string) error {
+	switch format {
+	case outputFormatRaw, outputFormatJSON, outputFormatKeyValue:
+		return nil
+	}
+	return fmt.Errorf("invalid output_format %q", format)
+}
+
+// parseCommandOutput turns command output into heartbeat fields. Numeric
+// key=value values are stored as numbers so they can be charted.
+func parseCommandOutput(format, output string) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	switch format {
+	case outputFormatJSON:
+		if err := json.Unmarshal([]byte(output), &fields); err != nil {
+			return nil, fmt.Errorf("not a JSON object: %v", err)
+		}
+	case outputFormatKeyValue:
+		for _, line := range strings.Split(output, "\n") {
+			k, v, ok := strings.Cut(strings.TrimSpace(line), "=")
+			if !ok || strings.TrimSpace(k) == "" {
+				continue
+			}
+			v = strings.Trim(strings.TrimSpace(v), `"'`)
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				fields[strings.TrimSpace(k)] = n
+			} else {
+				fields[strings.TrimSpace(k)] = v
+			}
+		}
+	}
+	return fields, nil
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// limitedBuffer keeps the first maxCommandOutput bytes written to it and discards
+// the rest, so a chatty command cannot exhaust memory.
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := maxCommandOutput - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
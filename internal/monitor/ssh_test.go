@@ -0,0 +1,36 @@
+package monitor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommandOutput(t *testing.T) {
+	tests := []struct {
+		format string
+		output string
+		want   map[string]interface{}
+	}{
+		{outputFormatRaw, "exit_code=0", map[string]interface{}{}},
+		{outputFormatJSON, `{"disk_pct": 42, "exit_code": 0, "mount": "/"}`,
+			map[string]interface{}{"disk_pct": 42.0, "exit_code": 0.0, "mount": "/"}},
+		{outputFormatKeyValue, "disk_pct=42\n exit_code = 0 \nmount=\"/var\"\nno separator\n=orphan\n",
+			map[string]interface{}{"disk_pct": 42.0, "exit_code": 0.0, "mount": "/var"}},
+		{outputFormatKeyValue, "", map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		got, err := parseCommandOutput(tt.format, tt.output)
+		if err != nil {
+			t.Errorf("parseCommandOutput(%q, %q): %v", tt.format, tt.output, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCommandOutput(%q, %q) = %v, want %v", tt.format, tt.output, got, tt.want)
+		}
+	}
+	for _, output := range []string{"", "42", `["a"]`, "{"} {
+		if _, err := parseCommandOutput(outputFormatJSON, output); err == nil {
+			t.Errorf("parseCommandOutput(json, %q): expected an error", output)
+		}
+	}
+}
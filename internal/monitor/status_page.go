@@ -3,6 +3,7 @@ package monitor
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -78,18 +79,16 @@ func (e *Engine) getStatusPage(c echo.Context) error {
 			continue
 		}
 
-		var lastHeartbeat Heartbeat
-		e.db.Get(&lastHeartbeat, "SELECT status FROM heartbeats WHERE monitor_id = ? ORDER BY timestamp DESC LIMIT 1", id)
+		lastHeartbeat, _ := e.latestHeartbeat(id)
 
 		// Calculate uptime (last 24h)
-		var upCount, totalCount int
-		e.db.Get(&totalCount, "SELECT COUNT(*) FROM heartbeats WHERE monitor_id = ? AND timestamp > DATETIME('now', '-24 hours')", id)
-		e.db.Get(&upCount, "SELECT COUNT(*) FROM heartbeats WHERE monitor_id = ? AND status = 'up' AND timestamp > DATETIME('now', '-24 hours')", id)
-
-		uptime := 100.0
-		if totalCount > 0 {
-			uptime = (float64(upCount) / float64(totalCount)) * 100
+		var counts struct {
+			Up    int `db:"up_count"`
+			Total int `db:"total_count"`
 		}
+		since := time.Now().UTC().Add(-24 * time.Hour)
+		e.db.Get(&counts, "SELECT COALESCE("+uptimeUpSQL+", 0) as up_count, COALESCE("+uptimeTotalSQL+", 0) as total_count FROM heartbeats WHERE monitor_id = ? AND timestamp > ?", id, since)
+		uptime := uptimePercent(counts.Up, counts.Total)
 
 		monitorsData = append(monitorsData, MonitorStatus{
 			ID:     m.ID,
@@ -0,0 +1,143 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const websocketDefaultTimeout = 10 * time.Second
+
+// websocketConfig is the metadata of websocket monitors. The target is a ws://
+// or wss:// URL.
+type websocketConfig struct {
+	Headers      map[string]string `json:"headers"`
+	Subprotocols []string          `json:"subprotocols"`
+	Send         string            `json:"send"`         // Text message sent after the handshake
+	ExpectRegex  string            `json:"expect_regex"` // A received message must match
+	Timeout      int               `json:"timeout"`      // seconds
+}
+
+func parseWebSocketConfig(metadata string) (websocketConfig, error) {
+	var cfg websocketConfig
+	json.Unmarshal([]byte(metadata), &cfg)
+	if cfg.ExpectRegex != "" {
+		if _, err := regexp.Compile(cfg.ExpectRegex); err != nil {
+			return cfg, fmt.Errorf("invalid expect_regex: %v", err)
+		}
+	}
+	return cfg, nil
+}
+
+func (cfg websocketConfig) timeout() time.Duration {
+	if cfg.Timeout > 0 {
+		return time.Duration(cfg.Timeout) * time.Second
+	}
+	return websocketDefaultTimeout
+}
+
+func (e *Engine) checkWebSocket(m Monitor) Result {
+	cfg, err := parseWebSocketConfig(m.Metadata)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}
+	}
+
+	network, err := parseNetworkConfig(m.Metadata)
+	if err != nil {
+		return Result{MonitorID: m.ID, Status: "down", Message: err.Error()}
+	}
+	tlsConfig, _ := network.tlsConfig()
+	dialer := websocket.Dialer{
+		HandshakeTimeout: cfg.timeout(),
+		Subprotocols:     cfg.Subprotocols,
+		Proxy:            http.ProxyFromEnvironment,
+		NetDialContext:   network.directDialer(cfg.timeout()),
+		TLSClientConfig:  tlsConfig,
+	}
+	if network.Proxy != "" {
+		u, _ := url.Parse(network.Proxy)
+		dialer.Proxy = http.ProxyURL(u)
+	}
+	header := http.Header{}
+	for k, v := range cfg.Headers {
+		header.Set(k, v)
+	}
+
+	ctx, cancel := context.WithTimeout(e.ctx, cfg.timeout())
+	defer cancel()
+
+	start := time.Now()
+	conn, resp, err := dialer.DialContext(ctx, m.Target, header)
+	handshakeMs := time.Since(start).Milliseconds()
+	if err != nil {
+		msg := fmt.Sprintf("Handshake failed: %v", err)
+		if resp != nil {
+			msg = fmt.Sprintf("Upgrade refused: %s", resp.Status)
+		}
+		return Result{MonitorID: m.ID, Status: "down", Latency: int(handshakeMs), Message: msg}
+	}
+	defer conn.Close()
+
+	data := map[string]interface{}{"handshake_ms": handshakeMs}
+	if p := conn.Subprotocol(); p != "" {
+		data["subprotocol"] = p
+	}
+	if len(cfg.Subprotocols) > 0 && conn.Subprotocol() == "" {
+		return Result{MonitorID: m.ID, Status: "down", Latency: int(handshakeMs), Message: "Server accepted none of the subprotocols", Data: marshalData(data)}
+	}
+
+	if cfg.Send == "" && cfg.ExpectRegex == "" {
+		closeWebSocket(conn)
+		return Result{MonitorID: m.ID, Status: "up", Latency: int(handshakeMs), Message: "WebSocket handshake successful", Data: marshalData(data)}
+	}
+
+	// Exchange: send the message (if any) and wait for a matching reply
+	deadline := time.Now().Add(cfg.timeout())
+	conn.SetWriteDeadline(deadline)
+	conn.SetReadDeadline(deadline)
+
+	sent := time.Now()
+	if cfg.Send != "" {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(cfg.Send)); err != nil {
+			return Result{MonitorID: m.ID, Status: "down", Latency: int(handshakeMs), Message: fmt.Sprintf("Send failed: %v", err), Data: marshalData(data)}
+		}
+	}
+
+	var re *regexp.Regexp
+	if cfg.ExpectRegex != "" {
+		re = regexp.MustCompile(cfg.ExpectRegex)
+	}
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			var netErr net.Error
+			reason := fmt.Sprintf("Connection dropped: %v", err)
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				reason = fmt.Sprintf("No matching reply within %s", cfg.timeout())
+			}
+			return Result{MonitorID: m.ID, Status: "down", Latency: int(handshakeMs), Message: reason, Data: marshalData(data)}
+		}
+		if re != nil && !re.Match(msg) {
+			continue
+		}
+
+		rtt := time.Since(sent).Milliseconds()
+		data["roundtrip_ms"] = rtt
+		data["response"] = describeResponse(msg)
+		closeWebSocket(conn)
+		return Result{MonitorID: m.ID, Status: "up", Latency: int(rtt), Message: "WebSocket reply received", Data: marshalData(data)}
+	}
+}
+
+// closeWebSocket performs a polite close; the caller still closes the socket.
+func closeWebSocket(conn *websocket.Conn) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+}
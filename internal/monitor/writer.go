@@ -0,0 +1,133 @@
+package monitor
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	heartbeatFlushInterval = time.Second
+	heartbeatBatchSize     = 500
+	heartbeatMaxPending    = 50000 // Heartbeats kept while the database is unavailable
+	heartbeatFinalAttempts = 3     // Flushes tried on shutdown before giving up
+)
+
+// queueHeartbeat hands a heartbeat to the writer, which commits the pending
+// heartbeats in one transaction every second or once a batch is full.
+func (e *Engine) queueHeartbeat(h Heartbeat) {
+	e.pendingMu.Lock()
+	e.pending = append(e.pending, h)
+	full := len(e.pending) >= heartbeatBatchSize
+	e.pendingMu.Unlock()
+	if full {
+		select {
+		case e.flushNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// heartbeatWriter flushes the queued heartbeats until the engine stops,
+// writing what is left on the way out.
+func (e *Engine) heartbeatWriter() {
+	defer close(e.writerDone)
+	ticker := time.NewTicker(heartbeatFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			e.drainHeartbeats()
+			return
+		case <-ticker.C:
+		case <-e.flushNow:
+		}
+		e.flushHeartbeats()
+	}
+}
+
+// flushHeartbeats writes the pending heartbeats. A failed batch is retried
+// with the next flush, dropping the oldest beyond heartbeatMaxPending.
+func (e *Engine) flushHeartbeats() {
+	e.flushMu.Lock()
+	defer e.flushMu.Unlock()
+
+	e.pendingMu.Lock()
+	batch := e.pending
+	e.pending = nil
+	e.pendingMu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	if err := e.insertHeartbeats(batch); err != nil {
+		log.Printf("Error saving %d heartbeats: %v", len(batch), err)
+		e.pendingMu.Lock()
+		e.pending = append(batch, e.pending...)
+		if over := len(e.pending) - heartbeatMaxPending; over > 0 {
+			e.pending = e.pending[over:]
+		}
+		e.pendingMu.Unlock()
+	}
+}
+
+// drainHeartbeats writes the pending heartbeats on shutdown, retrying while
+// the database is briefly unavailable.
+func (e *Engine) drainHeartbeats() {
+	for attempt := 1; attempt <= heartbeatFinalAttempts; attempt++ {
+		e.flushHeartbeats()
+		e.pendingMu.Lock()
+		left := len(e.pending)
+		e.pendingMu.Unlock()
+		if left == 0 {
+			return
+		}
+		if attempt == heartbeatFinalAttempts {
+			log.Printf("Shutting down with %d heartbeats not saved", left)
+			return
+		}
+		time.Sleep(time.Duration(attempt) * heartbeatFlushInterval)
+	}
+}
+
+func (e *Engine) insertHeartbeats(batch []Heartbeat) error {
+	tx, err := e.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Preparex(`
+		INSERT INTO heartbeats (monitor_id, status, latency, message, data, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, h := range batch {
+		if _, err := stmt.Exec(h.MonitorID, h.Status, h.Latency, h.Message, h.Data, h.Timestamp); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// setLatest records the newest heartbeat of a monitor for the dashboard and
+// reports whether h is newer than the one already known.
+func (e *Engine) setLatest(h Heartbeat) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if cur, ok := e.latest[h.MonitorID]; ok && !h.Timestamp.After(cur.Timestamp) {
+		return false
+	}
+	e.latest[h.MonitorID] = h
+	return true
+}
+
+func (e *Engine) latestHeartbeat(monitorID string) (Heartbeat, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	h, ok := e.latest[monitorID]
+	return h, ok
+}
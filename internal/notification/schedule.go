@@ -0,0 +1,185 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule modes
+const (
+	ScheduleModeActive = "active" // Deliver only inside the window
+	ScheduleModeMute   = "mute"   // Suppress inside the window
+)
+
+// What happens to events suppressed by a schedule
+const (
+	SuppressDrop  = "drop"
+	SuppressQueue = "queue"
+)
+
+// Schedule restricts when a notification channel may deliver events.
+// Stored as JSON in the notifications.schedule column, e.g.
+//
+//	{"mode":"active","days":["mon","tue","wed","thu","fri"],"start":"09:00","end":"18:00","timezone":"Asia/Shanghai"}
+//	{"mode":"mute","start":"23:00","end":"07:00","bypass_tags":["critical"],"suppressed":"queue"}
+type Schedule struct {
+	Mode       string   `json:"mode"`
+	Days       []string `json:"days"`
+	Start      string   `json:"start"`
+	End        string   `json:"end"`
+	Timezone   string   `json:"timezone"`
+	BypassTags []string `json:"bypass_tags"`
+	Suppressed string   `json:"suppressed"`
+
+	loc        *time.Location
+	startMin   int
+	endMin     int
+	dayAllowed map[time.Weekday]bool
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseSchedule parses a schedule JSON string. An empty string means the channel
+// has no schedule and always delivers, in which case nil is returned.
+func ParseSchedule(raw string) (*Schedule, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "{}" || raw == "null" {
+		return nil, nil
+	}
+
+	var s Schedule
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		return nil, fmt.Errorf("invalid schedule: %v", err)
+	}
+
+	switch s.Mode {
+	case "":
+		s.Mode = ScheduleModeActive
+	case ScheduleModeActive, ScheduleModeMute:
+	default:
+		return nil, fmt.Errorf("invalid schedule mode %q", s.Mode)
+	}
+
+	switch s.Suppressed {
+	case "":
+		s.Suppressed = SuppressDrop
+	case SuppressDrop, SuppressQueue:
+	default:
+		return nil, fmt.Errorf("invalid schedule suppressed action %q", s.Suppressed)
+	}
+
+	s.loc = time.UTC
+	if s.Timezone != "" {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule timezone %q: %v", s.Timezone, err)
+		}
+		s.loc = loc
+	}
+
+	var err error
+	if s.Start == "" && s.End == "" {
+		// Whole day
+		s.startMin, s.endMin = 0, 24*60
+	} else {
+		if s.startMin, err = parseClock(s.Start); err != nil {
+			return nil, err
+		}
+		if s.endMin, err = parseClock(s.End); err != nil {
+			return nil, err
+		}
+		if s.startMin == s.endMin {
+			// Equal start and end cover the whole day rather than nothing
+			s.startMin, s.endMin = 0, 24*60
+		}
+	}
+
+	if len(s.Days) > 0 {
+		s.dayAllowed = make(map[time.Weekday]bool)
+		for _, d := range s.Days {
+			key := strings.ToLower(strings.TrimSpace(d))
+			if len(key) > 3 {
+				key = key[:3] // Accept "monday" as well as "mon"
+			}
+			wd, ok := weekdays[key]
+			if !ok {
+				return nil, fmt.Errorf("invalid schedule day %q", d)
+			}
+			s.dayAllowed[wd] = true
+		}
+	}
+
+	return &s, nil
+}
+
+func parseClock(v string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(v))
+	if err != nil {
+		if v == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, fmt.Errorf("invalid schedule time %q, expected HH:MM", v)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// inWindow reports whether t falls inside the configured window. Windows that
+// cross midnight (e.g. 23:00-07:00) belong to the day on which they start.
+func (s *Schedule) inWindow(t time.Time) bool {
+	local := t.In(s.loc)
+	minute := local.Hour()*60 + local.Minute()
+
+	if s.startMin <= s.endMin {
+		return minute >= s.startMin && minute < s.endMin && s.dayOK(local.Weekday())
+	}
+
+	// Overnight window
+	if minute >= s.startMin {
+		return s.dayOK(local.Weekday())
+	}
+	if minute < s.endMin {
+		return s.dayOK(local.AddDate(0, 0, -1).Weekday())
+	}
+	return false
+}
+
+func (s *Schedule) dayOK(d time.Weekday) bool {
+	return s.dayAllowed == nil || s.dayAllowed[d]
+}
+
+// Allows reports whether an event for a monitor carrying the given tags may be
+// delivered at time t.
+func (s *Schedule) Allows(t time.Time, tags []string) bool {
+	if s == nil {
+		return true
+	}
+	for _, bt := range s.BypassTags {
+		for _, tag := range tags {
+			if strings.EqualFold(bt, tag) {
+				return true
+			}
+		}
+	}
+
+	in := s.inWindow(t)
+	if s.Mode == ScheduleModeMute {
+		return !in
+	}
+	return in
+}
+
+// QueueSuppressed reports whether suppressed events should be queued and
+// delivered as a summary once the channel is allowed to deliver again.
+func (s *Schedule) QueueSuppressed() bool {
+	return s != nil && s.Suppressed == SuppressQueue
+}
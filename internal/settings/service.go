@@ -5,7 +5,7 @@ import (
 	"os"
 	"sync"
 
-	"github.com/jmoiron/sqlx"
+	"aeromonitor/internal/db"
 )
 
 // Supported Setting Keys
@@ -38,15 +38,18 @@ var envMapping = map[string]string{
 	"OIDC_USERINFO_URL":   KeyOIDCUserInfoURL,
 }
 
+// upsertSetting inserts or overwrites a setting on SQLite and PostgreSQL alike.
+const upsertSetting = "INSERT INTO settings (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value"
+
 type Service struct {
-	db    *sqlx.DB
+	db    *db.DB
 	cache map[string]string
 	mu    sync.RWMutex
 }
 
-func NewService(db *sqlx.DB) *Service {
+func NewService(database *db.DB) *Service {
 	s := &Service{
-		db:    db,
+		db:    database,
 		cache: make(map[string]string),
 	}
 	s.loadSettings()
@@ -80,7 +83,7 @@ func (s *Service) loadSettings() {
 			if _, exists := s.cache[settingKey]; !exists {
 				log.Printf("Initializing setting %s from environment variable %s", settingKey, envKey)
 				// Persist to DB
-				_, err := s.db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", settingKey, envVal)
+				_, err := s.db.Exec(upsertSetting, settingKey, envVal)
 				if err != nil {
 					log.Printf("Failed to persist env setting %s: %v", settingKey, err)
 					continue
@@ -94,7 +97,7 @@ func (s *Service) loadSettings() {
 		log.Printf("Admin user exists but no authentication method is enabled. Enabling password authentication by default.")
 		s.cache[KeyAllowPasswordAuth] = "true"
 		// Best effort persist to DB
-		s.db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", KeyAllowPasswordAuth, "true")
+		s.db.Exec(upsertSetting, KeyAllowPasswordAuth, "true")
 	}
 }
 
@@ -113,7 +116,7 @@ func (s *Service) Set(key, value string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	_, err := s.db.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", key, value)
+	_, err := s.db.Exec(upsertSetting, key, value)
 	if err != nil {
 		return err
 	}
@@ -137,13 +140,13 @@ func (s *Service) SetAll(settings map[string]string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	tx, err := s.db.Begin()
+	tx, err := s.db.Beginx()
 	if err != nil {
 		return err
 	}
 
 	for k, v := range settings {
-		_, err := tx.Exec("INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)", k, v)
+		_, err := tx.Exec(upsertSetting, k, v)
 		if err != nil {
 			tx.Rollback()
 			return err
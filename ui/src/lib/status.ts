@@ -0,0 +1,27 @@
+// How a monitor status is shown. Degraded (a data rule or threshold was
+// violated) and late (a scheduled job has not reported yet) are warnings:
+// they render amber and, like on the server, count as available for uptime.
+// Maintenance heartbeats are left out of uptime altogether.
+export type StatusTone = 'up' | 'warning' | 'down' | 'unknown';
+
+export function statusTone(status?: string): StatusTone {
+    switch (status) {
+        case 'up':
+            return 'up';
+        case 'degraded':
+        case 'late':
+            return 'warning';
+        case 'down':
+            return 'down';
+        default:
+            return 'unknown';
+    }
+}
+
+// uptimePercent applies the server's uptime policy to a list of heartbeats.
+export function uptimePercent(statuses: string[]): number {
+    const counted = statuses.filter(s => s !== 'maintenance');
+    if (counted.length === 0) return 100;
+    const available = counted.filter(s => statusTone(s) === 'up' || statusTone(s) === 'warning').length;
+    return (available / counted.length) * 100;
+}
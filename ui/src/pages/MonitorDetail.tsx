@@ -2,9 +2,17 @@ import { useEffect, useState } from 'react';
 import { useParams, useNavigate } from 'react-router-dom';
 import axios from 'axios';
 import ReactECharts from 'echarts-for-react';
-import { CheckCircle2, XCircle, Zap, ShieldCheck, Clock, Trash2, Activity, Pause, Play, Download } from 'lucide-react';
+import { AlertTriangle, CheckCircle2, XCircle, Zap, ShieldCheck, Clock, Trash2, Activity, Pause, Play, Download } from 'lucide-react';
 import { useToast } from '../contexts/ToastContext';
 import { ConfirmDialog } from '../components/ConfirmDialog';
+import { statusTone, uptimePercent, type StatusTone } from '../lib/status';
+
+const badgeClasses: Record<StatusTone, string> = {
+    up: 'bg-emerald-500/10 text-emerald-400 border border-emerald-500/20',
+    warning: 'bg-amber-500/10 text-amber-400 border border-amber-500/20',
+    down: 'bg-red-500/10 text-red-400 border border-red-500/20',
+    unknown: 'bg-secondary text-muted-foreground border border-border',
+};
 
 interface Heartbeat {
     id: number;
@@ -21,6 +29,7 @@ const MonitorDetail = ({ user }: { user: any }) => {
     const navigate = useNavigate();
     const [monitor, setMonitor] = useState<any>(null);
     const [heartbeats, setHeartbeats] = useState<Heartbeat[]>([]);
+    const [uptime, setUptime] = useState<Record<string, number> | null>(null);
     const [isEditModalOpen, setIsEditModalOpen] = useState(false);
     const [isExportModalOpen, setIsExportModalOpen] = useState(false);
     const [activeTab, setActiveTab] = useState('basic');
@@ -228,12 +237,14 @@ const MonitorDetail = ({ user }: { user: any }) => {
 
     const fetchData = async () => {
         try {
-            const [mRes, hRes] = await Promise.all([
+            const [mRes, hRes, uRes] = await Promise.all([
                 axios.get(`/api/monitors/${id}`),
-                axios.get(`/api/monitors/${id}/heartbeats`)
+                axios.get(`/api/monitors/${id}/heartbeats`),
+                axios.get(`/api/monitors/${id}/uptime`)
             ]);
             setMonitor(mRes.data);
             setHeartbeats(hRes.data || []);
+            setUptime(uRes.data);
         } catch (err) {
             console.error('Failed to fetch data', err);
         }
@@ -256,9 +267,9 @@ const MonitorDetail = ({ user }: { user: any }) => {
     };
 
     const calculateUptime = () => {
+        if (uptime) return uptime['7d'].toFixed(1);
         if (heartbeats.length === 0) return "100.0";
-        const upCount = heartbeats.filter(h => h.status === 'up').length;
-        return ((upCount / heartbeats.length) * 100).toFixed(1);
+        return uptimePercent(heartbeats.map(h => h.status)).toFixed(1);
     };
 
     const renderChart = (title: string, data: any[] | { name: string, data: any[] }[], color: string, isFill: boolean = true) => {
@@ -407,8 +418,7 @@ const MonitorDetail = ({ user }: { user: any }) => {
                 <div>
                     <div className="flex items-center gap-3 mb-2">
                         <h2 className="text-3xl font-bold">{currentMonitor.name}</h2>
-                        <span className={`px-2 py-0.5 rounded text-xs font-bold uppercase tracking-wider ${heartbeats[0]?.status === 'up' ? 'bg-emerald-500/10 text-emerald-400 border border-emerald-500/20' : 'bg-red-500/10 text-red-400 border border-red-500/20'
-                            }`}>
+                        <span className={`px-2 py-0.5 rounded text-xs font-bold uppercase tracking-wider ${badgeClasses[statusTone(heartbeats[0]?.status)]}`}>
                             {heartbeats[0]?.status || 'Unknown'}
                         </span>
                         {currentMonitor.paused && (
@@ -475,6 +485,11 @@ const MonitorDetail = ({ user }: { user: any }) => {
                     <div>
                         <p className="text-muted-foreground text-sm font-medium">Uptime (7d)</p>
                         <p className="text-2xl font-bold">{calculateUptime()}%</p>
+                        {uptime && (
+                            <p className="text-xs text-muted-foreground font-mono">
+                                30d {uptime['30d'].toFixed(2)}% · 90d {uptime['90d'].toFixed(2)}%
+                            </p>
+                        )}
                     </div>
                 </div>
                 <div className="bg-card border border-border p-6 rounded-2xl flex items-center gap-4">
@@ -534,7 +549,7 @@ const MonitorDetail = ({ user }: { user: any }) => {
                 {/* Always show Latency chart (now contains 'ping' data for push monitors) */}
                 {renderChart(
                     'Response Times (ms)',
-                    heartbeats.map(h => h.status === 'up' ? h.latency : null),
+                    heartbeats.map(h => h.status === 'up' || h.status === 'degraded' ? h.latency : null),
                     colors[0]
                 )}
 
@@ -698,7 +713,11 @@ const MonitorDetail = ({ user }: { user: any }) => {
                             <div key={h.id} className="p-4 hover:bg-muted/50">
                                 <div className="flex items-center justify-between">
                                     <div className="flex items-center gap-4">
-                                        {h.status === 'up' ? <CheckCircle2 className="text-emerald-500" size={18} /> : <XCircle className="text-red-500" size={18} />}
+                                        {statusTone(h.status) === 'up'
+                                            ? <CheckCircle2 className="text-emerald-500" size={18} />
+                                            : statusTone(h.status) === 'warning'
+                                                ? <AlertTriangle className="text-amber-500" size={18} />
+                                                : <XCircle className="text-red-500" size={18} />}
                                         <span className="font-mono text-sm text-muted-foreground">{new Date(h.timestamp).toLocaleString()}</span>
                                         {dataDisplay}
                                     </div>
@@ -708,7 +727,7 @@ const MonitorDetail = ({ user }: { user: any }) => {
                                     </div>
                                 </div>
                                 {h.message && (
-                                    <div className={`mt-2 ml-7 text-xs ${h.status === 'down' ? 'text-red-400' : 'text-muted-foreground'}`}>
+                                    <div className={`mt-2 ml-7 text-xs ${h.status === 'down' ? 'text-red-400' : statusTone(h.status) === 'warning' ? 'text-amber-400' : 'text-muted-foreground'}`}>
                                         {h.message}
                                     </div>
                                 )}
@@ -1,6 +1,6 @@
 import { useState, useEffect } from 'react';
 import axios from 'axios';
-import { Bell, User, Globe, Mail, MessageSquare, Users, Send, Monitor, Plus, Trash2, Edit2, X, Shield, Key } from 'lucide-react';
+import { Bell, User, Globe, Mail, MessageSquare, Users, Send, Monitor, Plus, Trash2, Edit2, X, Shield, Key, MapPin } from 'lucide-react';
 import { useToast } from '../contexts/ToastContext';
 
 interface NotificationChannel {
@@ -8,6 +8,15 @@ interface NotificationChannel {
     name: string;
     type: string;
     config: string;
+    schedule?: string;
+}
+
+interface ProbeAgent {
+    id: string;
+    name: string;
+    location: string;
+    hostname: string;
+    last_seen: string | null;
 }
 
 const NOTIFICATION_TYPES = {
@@ -47,6 +56,9 @@ const NOTIFICATION_TYPES = {
 const Settings = () => {
     const { showToast } = useToast();
     const [notifChannels, setNotifChannels] = useState<NotificationChannel[]>([]);
+    const [agents, setAgents] = useState<ProbeAgent[]>([]);
+    const [agentForm, setAgentForm] = useState({ name: '', location: '' });
+    const [agentToken, setAgentToken] = useState<string | null>(null);
 
     // Auth Settings State
     const [settings, setSettings] = useState({
@@ -69,7 +81,8 @@ const Settings = () => {
     const [editForm, setEditForm] = useState({
         name: '',
         type: 'slack',
-        config: {} as any
+        config: {} as any,
+        schedule: ''
     });
 
     useEffect(() => {
@@ -78,12 +91,14 @@ const Settings = () => {
 
     const fetchData = async () => {
         try {
-            const [settingsRes, notifRes] = await Promise.all([
+            const [settingsRes, notifRes, agentsRes] = await Promise.all([
                 axios.get('/api/settings'),
-                axios.get('/api/notifications')
+                axios.get('/api/notifications'),
+                axios.get('/api/agents')
             ]);
             setSettings(prev => ({ ...prev, ...settingsRes.data }));
             setNotifChannels(notifRes.data || []);
+            setAgents(agentsRes.data || []);
         } catch (err) {
             console.error("Failed to load settings", err);
         }
@@ -104,7 +119,7 @@ const Settings = () => {
 
     const openAddModal = () => {
         setEditingId(null);
-        setEditForm({ name: '', type: 'slack', config: {} });
+        setEditForm({ name: '', type: 'slack', config: {}, schedule: '' });
         setIsModalOpen(true);
     };
 
@@ -114,7 +129,7 @@ const Settings = () => {
         try {
             config = JSON.parse(channel.config);
         } catch (e) { }
-        setEditForm({ name: channel.name, type: channel.type, config });
+        setEditForm({ name: channel.name, type: channel.type, config, schedule: channel.schedule || '' });
         setIsModalOpen(true);
     };
 
@@ -135,7 +150,8 @@ const Settings = () => {
             const payload = {
                 name: editForm.name,
                 type: editForm.type,
-                config: JSON.stringify(editForm.config)
+                config: JSON.stringify(editForm.config),
+                schedule: editForm.schedule
             };
 
             if (editingId) {
@@ -152,6 +168,29 @@ const Settings = () => {
         }
     };
 
+    const handleCreateAgent = async (e: React.FormEvent) => {
+        e.preventDefault();
+        try {
+            const res = await axios.post('/api/agents', agentForm);
+            setAgents(prev => [...prev, res.data.agent]);
+            setAgentToken(res.data.token);
+            setAgentForm({ name: '', location: '' });
+        } catch (err: any) {
+            showToast(err.response?.data?.error || 'Failed to create agent', 'error');
+        }
+    };
+
+    const handleDeleteAgent = async (agent: ProbeAgent) => {
+        if (!confirm(`Are you sure you want to delete agent ${agent.name}?`)) return;
+        try {
+            await axios.delete(`/api/agents/${agent.id}`);
+            setAgents(prev => prev.filter(a => a.id !== agent.id));
+            showToast('Agent deleted', 'info');
+        } catch (err) {
+            showToast('Failed to delete agent', 'error');
+        }
+    };
+
     const handleTestNotification = async () => {
         try {
             await axios.post('/api/notifications/test', {
@@ -258,6 +297,79 @@ const Settings = () => {
                 </div>
             </section>
 
+            <section className="bg-card border border-border rounded-2xl p-8">
+                <div className="flex items-center gap-4 mb-8">
+                    <div className="bg-sky-500/10 p-3 rounded-xl text-sky-500">
+                        <MapPin size={24} />
+                    </div>
+                    <div>
+                        <h3 className="text-xl font-bold">Probe Agents</h3>
+                        <p className="text-muted-foreground text-sm">Check monitors from other locations and networks</p>
+                    </div>
+                </div>
+
+                <div className="space-y-4">
+                    {agents.length > 0 && (
+                        <div className="grid gap-2">
+                            {agents.map(agent => (
+                                <div key={agent.id} className="border border-border rounded-xl p-4 flex items-center justify-between group">
+                                    <div>
+                                        <h4 className="font-bold">{agent.name}</h4>
+                                        <div className="flex items-center gap-2 text-xs text-muted-foreground">
+                                            <span className="bg-secondary px-2 py-0.5 rounded font-mono">{agent.location}</span>
+                                            {agent.hostname && <span>{agent.hostname}</span>}
+                                            <span>{agent.last_seen ? `Last seen ${new Date(agent.last_seen).toLocaleString()}` : 'Never connected'}</span>
+                                        </div>
+                                    </div>
+                                    <button
+                                        onClick={() => handleDeleteAgent(agent)}
+                                        className="p-2 hover:bg-destructive/10 rounded-lg transition-colors text-muted-foreground hover:text-destructive opacity-0 group-hover:opacity-100"
+                                        title="Delete"
+                                    >
+                                        <Trash2 size={18} />
+                                    </button>
+                                </div>
+                            ))}
+                        </div>
+                    )}
+
+                    {agentToken && (
+                        <div className="border border-amber-500/30 bg-amber-500/5 rounded-xl p-4 space-y-2">
+                            <p className="text-sm">Copy the agent token now, it will not be shown again:</p>
+                            <code className="block bg-background border border-border rounded-lg px-3 py-2 text-xs font-mono break-all">{agentToken}</code>
+                            <p className="text-xs text-muted-foreground">
+                                Run <code>aeromonitor agent -server {window.location.origin} -token &lt;token&gt;</code> at the location.
+                            </p>
+                        </div>
+                    )}
+
+                    <form onSubmit={handleCreateAgent} className="flex gap-2">
+                        <input
+                            value={agentForm.name}
+                            onChange={e => setAgentForm({ ...agentForm, name: e.target.value })}
+                            className="bg-background border border-border rounded-lg px-3 py-2 text-sm focus:ring-2 focus:ring-primary outline-none flex-1"
+                            placeholder="Name (e.g. Frankfurt DC)"
+                        />
+                        <input
+                            required
+                            value={agentForm.location}
+                            onChange={e => setAgentForm({ ...agentForm, location: e.target.value })}
+                            className="bg-background border border-border rounded-lg px-3 py-2 text-sm focus:ring-2 focus:ring-primary outline-none flex-1 font-mono"
+                            placeholder="Location (e.g. fra)"
+                        />
+                        <button
+                            type="submit"
+                            className="bg-primary hover:bg-primary/90 text-primary-foreground font-medium py-2 px-4 rounded-lg transition-colors flex items-center gap-2"
+                        >
+                            <Plus size={16} /> Add Agent
+                        </button>
+                    </form>
+                    <p className="text-xs text-muted-foreground">
+                        Assign monitors with <code>"locations": ["local", "fra"]</code> in their metadata; <code>local</code> is this server.
+                    </p>
+                </div>
+            </section>
+
             <section className="bg-card border border-border rounded-2xl p-8">
                 <div className="flex items-center justify-between mb-8">
                     <div className="flex items-center gap-4">
@@ -499,6 +611,17 @@ const Settings = () => {
                                 ))}
                             </div>
 
+                            <div className="space-y-1.5 pt-2 border-t border-border">
+                                <label className="text-sm font-medium text-muted-foreground">Quiet Hours Schedule (JSON, optional)</label>
+                                <textarea
+                                    rows={3}
+                                    value={editForm.schedule}
+                                    onChange={e => setEditForm({ ...editForm, schedule: e.target.value })}
+                                    className="w-full bg-background border border-border rounded-lg px-3 py-2 text-xs font-mono text-foreground focus:ring-2 focus:ring-primary outline-none"
+                                    placeholder='{"mode":"mute","start":"23:00","end":"07:00","timezone":"Asia/Shanghai","bypass_tags":["critical"],"suppressed":"queue"}'
+                                />
+                            </div>
+
                             <div className="flex gap-3 pt-4">
                                 <button
                                     type="button"
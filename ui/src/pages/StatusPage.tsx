@@ -2,6 +2,21 @@ import { useEffect, useState } from 'react';
 import { useParams } from 'react-router-dom';
 import axios from 'axios';
 import { CheckCircle2, AlertCircle, Globe } from 'lucide-react';
+import { statusTone, type StatusTone } from '../lib/status';
+
+const dotClasses: Record<StatusTone, string> = {
+    up: 'bg-emerald-500 shadow-[0_0_12px_rgba(16,185,129,0.4)]',
+    warning: 'bg-amber-500 shadow-[0_0_12px_rgba(245,158,11,0.4)]',
+    down: 'bg-red-500 shadow-[0_0_12px_rgba(239,68,68,0.4)]',
+    unknown: 'bg-slate-500',
+};
+
+const badgeClasses: Record<StatusTone, string> = {
+    up: 'bg-emerald-500/10 text-emerald-400 border-emerald-500/20',
+    warning: 'bg-amber-500/10 text-amber-400 border-amber-500/20',
+    down: 'bg-red-500/10 text-red-400 border-red-500/20',
+    unknown: 'bg-secondary text-muted-foreground border-border',
+};
 
 interface MonitorStatus {
     id: string;
@@ -53,8 +68,8 @@ const StatusPage = () => {
         </div>
     );
 
-    const allUp = data.monitors.every(m => m.status === 'up');
-    const someDown = data.monitors.some(m => m.status === 'down');
+    const allUp = data.monitors.every(m => statusTone(m.status) === 'up');
+    const someDown = data.monitors.some(m => statusTone(m.status) === 'down');
 
     return (
         <div className="min-h-screen bg-background text-foreground selection:bg-primary/30">
@@ -100,8 +115,7 @@ const StatusPage = () => {
                         {data.monitors.map((m) => (
                             <div key={m.id} className="px-8 py-6 flex flex-col md:flex-row md:items-center justify-between gap-4 hover:bg-muted/30 transition-colors">
                                 <div className="flex items-center gap-4">
-                                    <div className={`w-3 h-3 rounded-full animate-pulse ${m.status === 'up' ? 'bg-emerald-500 shadow-[0_0_12px_rgba(16,185,129,0.4)]' : 'bg-red-500 shadow-[0_0_12px_rgba(239,68,68,0.4)]'
-                                        }`} />
+                                    <div className={`w-3 h-3 rounded-full animate-pulse ${dotClasses[statusTone(m.status)]}`} />
                                     <div>
                                         <div className="font-bold text-lg">{m.name}</div>
                                         <div className="text-xs text-muted-foreground uppercase tracking-tighter">{m.type}</div>
@@ -112,10 +126,7 @@ const StatusPage = () => {
                                         <div className="text-muted-foreground text-xs font-medium uppercase mb-0.5">Uptime (24h)</div>
                                         <div className="font-mono font-bold text-lg">{m.uptime.toFixed(2)}%</div>
                                     </div>
-                                    <div className={`px-4 py-1.5 rounded-full text-xs font-bold uppercase tracking-widest border ${m.status === 'up'
-                                        ? 'bg-emerald-500/10 text-emerald-400 border-emerald-500/20'
-                                        : 'bg-red-500/10 text-red-400 border-red-500/20'
-                                        }`}>
+                                    <div className={`px-4 py-1.5 rounded-full text-xs font-bold uppercase tracking-widest border ${badgeClasses[statusTone(m.status)]}`}>
                                         {m.status}
                                     </div>
                                 </div>